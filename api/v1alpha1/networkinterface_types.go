@@ -46,8 +46,40 @@ type NetworkInterfaceStatus struct {
 
 	// ParentCIDR is the parent cidr of the Address
 	ParentCIDR string `json:"parentCidr,omitempty"`
+
+	// PodCIDR is the prefix the pods of the node get their address from
+	// +optional
+	PodCIDR string `json:"podCidr,omitempty"`
+
+	// Phase is the phase of the interface
+	// +optional
+	Phase NetworkInterfacePhase `json:"phase,omitempty"`
+
+	// Conditions are the conditions of the interface
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
+// +kubebuilder:validation:Enum=Pending;Ready;Degraded
+// NetworkInterfacePhase represents the phase of a NetworkInterface
+type NetworkInterfacePhase string
+
+const (
+	// NetworkInterfacePhasePending means the interface is not configured on the node yet
+	NetworkInterfacePhasePending NetworkInterfacePhase = "Pending"
+	// NetworkInterfacePhaseReady means the interface is configured on the node
+	NetworkInterfacePhaseReady NetworkInterfacePhase = "Ready"
+	// NetworkInterfacePhaseDegraded means the private NIC backing the interface is missing or unusable
+	NetworkInterfacePhaseDegraded NetworkInterfacePhase = "Degraded"
+)
+
+const (
+	// NetworkInterfaceDegraded is true when the private NIC backing the interface could not be found or recreated
+	NetworkInterfaceDegraded ConditionType = "Degraded"
+	// NetworkInterfaceReachable is true when all the health check targets are reachable through the interface
+	NetworkInterfaceReachable ConditionType = "Reachable"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster,shortName=ni;nif;networkinterface;netiface;niface
@@ -55,6 +87,7 @@ type NetworkInterfaceStatus struct {
 // +kubebuilder:printcolumn:name="node name",type="string",JSONPath=".spec.nodeName"
 // +kubebuilder:printcolumn:name="mac address",type="string",JSONPath=".status.macAddress"
 // +kubebuilder:printcolumn:name="link name",type="string",JSONPath=".status.linkName"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
 
 // NetworkInterface is the Schema for the networkinterfaces API
 type NetworkInterface struct {
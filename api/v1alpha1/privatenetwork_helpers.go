@@ -0,0 +1,247 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxInterfaceNameLength is the maximum length of a Linux interface name (IFNAMSIZ - 1)
+const maxInterfaceNameLength = 15
+
+// interfaceNameData is the data the InterfaceName template is rendered with
+type interfaceNameData struct {
+	Name string
+	ID   string
+}
+
+// RenderInterfaceName renders the InterfaceName template of the PrivateNetwork.
+// It returns an empty string if no template is set.
+func (pn *PrivateNetwork) RenderInterfaceName() (string, error) {
+	if pn.Spec.InterfaceName == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("interfaceName").Option("missingkey=error").Parse(pn.Spec.InterfaceName)
+	if err != nil {
+		return "", fmt.Errorf("invalid interfaceName template: %w", err)
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, interfaceNameData{
+		Name: pn.Name,
+		ID:   pn.PrivateNetworkID(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to render interfaceName template: %w", err)
+	}
+
+	name := b.String()
+	if len(name) == 0 || len(name) > maxInterfaceNameLength {
+		return "", fmt.Errorf("interface name %q must be between 1 and %d characters", name, maxInterfaceNameLength)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, "/: \t\n") {
+		return "", fmt.Errorf("interface name %q is not valid", name)
+	}
+
+	return name, nil
+}
+
+// NodeLabelSelector returns the selector of the nodes the PrivateNetwork is attached to
+func (pn *PrivateNetwork) NodeLabelSelector() (labels.Selector, error) {
+	if pn.Spec.NodeSelector == nil {
+		return labels.Everything(), nil
+	}
+	return metav1.LabelSelectorAsSelector(pn.Spec.NodeSelector)
+}
+
+// ValidatePodAttachment checks that the pods of the PrivateNetwork get their addresses from a range
+// of the static CIDR that is not used by the nodes
+func (pn *PrivateNetwork) ValidatePodAttachment() error {
+	pa := pn.Spec.PodAttachment
+	if pa == nil {
+		return nil
+	}
+
+	if pn.Spec.IPAM != nil && pn.Spec.IPAM.Static != nil && pn.Spec.IPAM.Static.PodCIDRSize != 0 {
+		return fmt.Errorf("podAttachment and ipam.static.podCIDRSize are mutually exclusive")
+	}
+	if pn.Spec.InterfaceName == "" {
+		return fmt.Errorf("podAttachment requires an interfaceName, so that the link has the same name on all the nodes")
+	}
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Type != IPAMTypeStatic || pn.Spec.IPAM.Static == nil {
+		return fmt.Errorf("podAttachment requires a static IPAM")
+	}
+	if len(pn.Spec.IPAM.Static.AvailableRanges) == 0 {
+		return fmt.Errorf("podAttachment requires availableRanges, so that the nodes don't use the whole CIDR")
+	}
+
+	_, cidr, err := net.ParseCIDR(pn.Spec.IPAM.Static.CIDR)
+	if err != nil {
+		return fmt.Errorf("invalid static CIDR %s: %w", pn.Spec.IPAM.Static.CIDR, err)
+	}
+	_, podCIDR, err := net.ParseCIDR(pa.CIDR)
+	if err != nil {
+		return fmt.Errorf("invalid podAttachment CIDR %s: %w", pa.CIDR, err)
+	}
+	podOnes, _ := podCIDR.Mask.Size()
+	ones, _ := cidr.Mask.Size()
+	if !cidr.Contains(podCIDR.IP) || podOnes < ones {
+		return fmt.Errorf("podAttachment CIDR %s is not in the static CIDR %s", pa.CIDR, pn.Spec.IPAM.Static.CIDR)
+	}
+
+	if pa.NodePrefixLength != 0 && int(pa.NodePrefixLength) < podOnes {
+		return fmt.Errorf("podAttachment nodePrefixLength %d is shorter than the CIDR %s", pa.NodePrefixLength, pa.CIDR)
+	}
+
+	for _, r := range pn.Spec.IPAM.Static.AvailableRanges {
+		_, availableRange, err := net.ParseCIDR(r)
+		if err != nil {
+			return fmt.Errorf("invalid available range %s: %w", r, err)
+		}
+		if availableRange.Contains(podCIDR.IP) || podCIDR.Contains(availableRange.IP) {
+			return fmt.Errorf("podAttachment CIDR %s overlaps the available range %s", pa.CIDR, r)
+		}
+	}
+	return nil
+}
+
+// ValidatePodCIDRSize checks that the pod CIDRs of the nodes can be carved out of the static CIDR
+func (pn *PrivateNetwork) ValidatePodCIDRSize() error {
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Static == nil || pn.Spec.IPAM.Static.PodCIDRSize == 0 {
+		return nil
+	}
+	static := pn.Spec.IPAM.Static
+
+	if len(static.AvailableRanges) == 0 {
+		return fmt.Errorf("podCIDRSize requires availableRanges, so that the nodes don't use the whole CIDR")
+	}
+	_, cidr, err := net.ParseCIDR(static.CIDR)
+	if err != nil {
+		return fmt.Errorf("invalid static CIDR %s: %w", static.CIDR, err)
+	}
+	ones, bits := cidr.Mask.Size()
+	if int(static.PodCIDRSize) <= ones || int(static.PodCIDRSize) > bits {
+		return fmt.Errorf("podCIDRSize %d must be longer than the static CIDR %s", static.PodCIDRSize, static.CIDR)
+	}
+	return nil
+}
+
+// ValidateIngressRules checks the source CIDRs and the ports of the ingress rules
+func (pn *PrivateNetwork) ValidateIngressRules() error {
+	if pn.Spec.IngressRules == nil {
+		return nil
+	}
+
+	for i, rule := range pn.Spec.IngressRules.Rules {
+		for _, from := range rule.From {
+			_, _, err := net.ParseCIDR(from)
+			if err != nil {
+				return fmt.Errorf("invalid source CIDR %s in ingress rule %d: %w", from, i, err)
+			}
+		}
+		if len(rule.Ports) != 0 && rule.Protocol != IngressProtocolTCP && rule.Protocol != IngressProtocolUDP {
+			return fmt.Errorf("ports of ingress rule %d require the TCP or UDP protocol", i)
+		}
+		for _, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("invalid port %d in ingress rule %d", port, i)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateDNS checks that the records can be published
+func (pn *PrivateNetwork) ValidateDNS() error {
+	dns := pn.Spec.DNS
+	if dns == nil {
+		return nil
+	}
+
+	if strings.Trim(dns.Domain, ".") == "" {
+		return fmt.Errorf("dns requires a domain")
+	}
+
+	if dns.RFC2136 != nil {
+		_, _, err := net.SplitHostPort(dns.RFC2136.Server)
+		if err != nil {
+			return fmt.Errorf("invalid dns server %s: %w", dns.RFC2136.Server, err)
+		}
+		domain := strings.ToLower(strings.Trim(dns.Domain, "."))
+		zone := strings.ToLower(strings.Trim(dns.RFC2136.Zone, "."))
+		if zone != "" && domain != zone && !strings.HasSuffix(domain, "."+zone) {
+			return fmt.Errorf("domain %s is not in zone %s", dns.Domain, dns.RFC2136.Zone)
+		}
+		if (dns.RFC2136.TSIGKeyName == "") != (dns.RFC2136.TSIGSecretRef == nil) {
+			return fmt.Errorf("tsigKeyName and tsigSecretRef must be set together")
+		}
+	}
+	return nil
+}
+
+// PrivateNetworkID returns the ID of the Scaleway private network, the one created by the controller
+// if the PrivateNetwork is managed. It is empty until the managed private network is created.
+func (pn *PrivateNetwork) PrivateNetworkID() string {
+	if pn.Spec.Managed != nil {
+		return pn.Status.ID
+	}
+	return pn.Spec.ID
+}
+
+// IsRegional returns whether the PrivateNetwork is a regional private network
+func (pn *PrivateNetwork) IsRegional() bool {
+	return pn.Spec.Region != ""
+}
+
+// ValidateLocality checks that the PrivateNetwork is either zonal or regional
+func (pn *PrivateNetwork) ValidateLocality() error {
+	if pn.Spec.Zone != "" && pn.Spec.Region != "" {
+		return fmt.Errorf("zone and region are mutually exclusive")
+	}
+	return nil
+}
+
+// ValidateCredentialsSecretRef checks that the credentials Secret is fully referenced,
+// since the PrivateNetwork is cluster-scoped and has no namespace to default to
+func (pn *PrivateNetwork) ValidateCredentialsSecretRef() error {
+	ref := pn.Spec.CredentialsSecretRef
+	if ref == nil {
+		return nil
+	}
+	if ref.Name == "" || ref.Namespace == "" {
+		return fmt.Errorf("credentialsSecretRef requires a name and a namespace")
+	}
+	return nil
+}
+
+// ValidateManaged checks that the PrivateNetwork either references a private network or is managed
+func (pn *PrivateNetwork) ValidateManaged() error {
+	if pn.Spec.Managed == nil && pn.Spec.ID == "" {
+		return fmt.Errorf("id is required unless the private network is managed")
+	}
+	if pn.Spec.Managed != nil && pn.Spec.ID != "" {
+		return fmt.Errorf("id and managed are mutually exclusive")
+	}
+	return nil
+}
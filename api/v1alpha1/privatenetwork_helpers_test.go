@@ -0,0 +1,521 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderInterfaceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		pn      PrivateNetwork
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no template",
+			pn:   PrivateNetwork{ObjectMeta: metav1.ObjectMeta{Name: "pn"}},
+			want: "",
+		},
+		{
+			name: "static name",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "priv0"},
+			},
+			want: "priv0",
+		},
+		{
+			name: "name of the PrivateNetwork",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "backend"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "vpc-{{ .Name }}"},
+			},
+			want: "vpc-backend",
+		},
+		{
+			name: "ID of the private network",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{ID: "0123456789", InterfaceName: "pn{{ slice .ID 0 8 }}"},
+			},
+			want: "pn01234567",
+		},
+		{
+			name: "ID of the managed private network",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec: PrivateNetworkSpec{
+					Managed:       &PrivateNetworkManaged{},
+					InterfaceName: "pn{{ .ID }}",
+				},
+				Status: PrivateNetworkStatus{ID: "abcd"},
+			},
+			want: "pnabcd",
+		},
+		{
+			name: "invalid template",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "vpc-{{ .Name"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "vpc-{{ .Zone }}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "too long",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "a-very-long-name"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "vpc-{{ .Name }}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "{{ .ID }}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid characters",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: "vpc/{{ .Name }}"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dot",
+			pn: PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "pn"},
+				Spec:       PrivateNetworkSpec{InterfaceName: ".."},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.pn.RenderInterfaceName()
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("interface name = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidatePodAttachment(t *testing.T) {
+	valid := func() PrivateNetworkSpec {
+		return PrivateNetworkSpec{
+			InterfaceName: "priv0",
+			IPAM: &PrivateNetworkIPAM{
+				Type: IPAMTypeStatic,
+				Static: &PrivateNetworkIPAMStatic{
+					CIDR:            "10.0.0.0/16",
+					AvailableRanges: []string{"10.0.0.0/24"},
+				},
+			},
+			PodAttachment: &PrivateNetworkPodAttachment{
+				CIDR:             "10.0.128.0/17",
+				NodePrefixLength: 24,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(spec *PrivateNetworkSpec)
+		wantErr bool
+	}{
+		{
+			name:   "no pod attachment",
+			mutate: func(spec *PrivateNetworkSpec) { spec.PodAttachment = nil },
+		},
+		{
+			name:   "valid",
+			mutate: func(spec *PrivateNetworkSpec) {},
+		},
+		{
+			name:    "with podCIDRSize",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.IPAM.Static.PodCIDRSize = 24 },
+			wantErr: true,
+		},
+		{
+			name:    "without interfaceName",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.InterfaceName = "" },
+			wantErr: true,
+		},
+		{
+			name:    "with dhcp",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.IPAM = &PrivateNetworkIPAM{Type: IPAMTypeDHCP} },
+			wantErr: true,
+		},
+		{
+			name:    "without available ranges",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.IPAM.Static.AvailableRanges = nil },
+			wantErr: true,
+		},
+		{
+			name:    "outside of the static cidr",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.PodAttachment.CIDR = "10.1.0.0/24" },
+			wantErr: true,
+		},
+		{
+			name:    "larger than the static cidr",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.PodAttachment.CIDR = "10.0.0.0/8" },
+			wantErr: true,
+		},
+		{
+			name:    "node prefix shorter than the cidr",
+			mutate:  func(spec *PrivateNetworkSpec) { spec.PodAttachment.NodePrefixLength = 16 },
+			wantErr: true,
+		},
+		{
+			name: "overlapping an available range",
+			mutate: func(spec *PrivateNetworkSpec) {
+				spec.IPAM.Static.AvailableRanges = append(spec.IPAM.Static.AvailableRanges, "10.0.128.0/24")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: valid()}
+			test.mutate(&pn.Spec)
+			err := pn.ValidatePodAttachment()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePodCIDRSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		static  *PrivateNetworkIPAMStatic
+		wantErr bool
+	}{
+		{
+			name:   "no static ipam",
+			static: nil,
+		},
+		{
+			name:   "no pod cidrs",
+			static: &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16"},
+		},
+		{
+			name:   "valid size",
+			static: &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16", AvailableRanges: []string{"10.0.0.0/24"}, PodCIDRSize: 24},
+		},
+		{
+			name:    "missing available ranges",
+			static:  &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16", PodCIDRSize: 24},
+			wantErr: true,
+		},
+		{
+			name:    "size of the static cidr",
+			static:  &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16", AvailableRanges: []string{"10.0.0.0/24"}, PodCIDRSize: 16},
+			wantErr: true,
+		},
+		{
+			name:    "size too long",
+			static:  &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16", AvailableRanges: []string{"10.0.0.0/24"}, PodCIDRSize: 33},
+			wantErr: true,
+		},
+		{
+			name:    "invalid static cidr",
+			static:  &PrivateNetworkIPAMStatic{CIDR: "10.0.0.0", AvailableRanges: []string{"10.0.0.0/24"}, PodCIDRSize: 24},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{}
+			if test.static != nil {
+				pn.Spec.IPAM = &PrivateNetworkIPAM{Type: IPAMTypeStatic, Static: test.static}
+			}
+			err := pn.ValidatePodCIDRSize()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateIngressRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   *PrivateNetworkIngressRules
+		wantErr bool
+	}{
+		{
+			name: "no ingress rules",
+		},
+		{
+			name: "valid rules",
+			rules: &PrivateNetworkIngressRules{
+				DefaultDeny: true,
+				Rules: []PrivateNetworkIngressRule{
+					{From: []string{"10.0.0.0/24"}, Protocol: IngressProtocolTCP, Ports: []int32{22, 65535}},
+					{Protocol: IngressProtocolICMP},
+					{},
+				},
+			},
+		},
+		{
+			name: "source without mask",
+			rules: &PrivateNetworkIngressRules{
+				Rules: []PrivateNetworkIngressRule{{From: []string{"10.0.0.1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ports without protocol",
+			rules: &PrivateNetworkIngressRules{
+				Rules: []PrivateNetworkIngressRule{{Ports: []int32{80}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ports with icmp",
+			rules: &PrivateNetworkIngressRules{
+				Rules: []PrivateNetworkIngressRule{{Protocol: IngressProtocolICMP, Ports: []int32{80}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "port out of range",
+			rules: &PrivateNetworkIngressRules{
+				Rules: []PrivateNetworkIngressRule{{Protocol: IngressProtocolUDP, Ports: []int32{0}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: PrivateNetworkSpec{IngressRules: test.rules}}
+			err := pn.ValidateIngressRules()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		dns     *PrivateNetworkDNS
+		wantErr bool
+	}{
+		{
+			name: "no dns",
+		},
+		{
+			name: "configMap",
+			dns:  &PrivateNetworkDNS{Domain: "vpc.internal.", ConfigMap: &PrivateNetworkDNSConfigMap{}},
+		},
+		{
+			name:    "missing domain",
+			dns:     &PrivateNetworkDNS{Domain: "."},
+			wantErr: true,
+		},
+		{
+			name: "rfc2136 with tsig",
+			dns: &PrivateNetworkDNS{
+				Domain: "nodes.vpc.internal",
+				RFC2136: &PrivateNetworkDNSRFC2136{
+					Server:        "192.168.0.2:53",
+					Zone:          "VPC.internal.",
+					TSIGKeyName:   "key",
+					TSIGSecretRef: &corev1.SecretReference{Name: "tsig", Namespace: "kube-system"},
+				},
+			},
+		},
+		{
+			name: "server without port",
+			dns: &PrivateNetworkDNS{
+				Domain:  "vpc.internal",
+				RFC2136: &PrivateNetworkDNSRFC2136{Server: "192.168.0.2", Zone: "vpc.internal"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "domain outside of the zone",
+			dns: &PrivateNetworkDNS{
+				Domain:  "othervpc.internal",
+				RFC2136: &PrivateNetworkDNSRFC2136{Server: "192.168.0.2:53", Zone: "vpc.internal"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tsig key name without secret",
+			dns: &PrivateNetworkDNS{
+				Domain:  "vpc.internal",
+				RFC2136: &PrivateNetworkDNSRFC2136{Server: "192.168.0.2:53", Zone: "vpc.internal", TSIGKeyName: "key"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: PrivateNetworkSpec{DNS: test.dns}}
+			err := pn.ValidateDNS()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateManaged(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		managed *PrivateNetworkManaged
+		wantErr bool
+	}{
+		{
+			name: "existing private network",
+			id:   "11111111-2222-3333-4444-555555555555",
+		},
+		{
+			name:    "managed private network",
+			managed: &PrivateNetworkManaged{Name: "pn"},
+		},
+		{
+			name:    "neither",
+			wantErr: true,
+		},
+		{
+			name:    "both",
+			id:      "11111111-2222-3333-4444-555555555555",
+			managed: &PrivateNetworkManaged{},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: PrivateNetworkSpec{ID: test.id, Managed: test.managed}}
+			err := pn.ValidateManaged()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLocality(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		region  string
+		wantErr bool
+	}{
+		{
+			name: "default zone of the client",
+		},
+		{
+			name: "zonal",
+			zone: "fr-par-1",
+		},
+		{
+			name:   "regional",
+			region: "fr-par",
+		},
+		{
+			name:    "zonal and regional",
+			zone:    "fr-par-1",
+			region:  "fr-par",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: PrivateNetworkSpec{Zone: test.zone, Region: test.region}}
+			err := pn.ValidateLocality()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsSecretRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     *corev1.SecretReference
+		wantErr bool
+	}{
+		{
+			name: "default credentials",
+		},
+		{
+			name: "name and namespace",
+			ref:  &corev1.SecretReference{Name: "team-a-credentials", Namespace: "scaleway-k8s-vpc-system"},
+		},
+		{
+			name:    "missing namespace",
+			ref:     &corev1.SecretReference{Name: "team-a-credentials"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			ref:     &corev1.SecretReference{Namespace: "scaleway-k8s-vpc-system"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pn := &PrivateNetwork{Spec: PrivateNetworkSpec{CredentialsSecretRef: test.ref}}
+			err := pn.ValidateCredentialsSecretRef()
+			if test.wantErr != (err != nil) {
+				t.Errorf("err = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
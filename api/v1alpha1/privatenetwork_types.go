@@ -17,19 +17,43 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // PrivateNetworkSpec defines the desired state of PrivateNetwork
 type PrivateNetworkSpec struct {
 	// ID is the ID of the PrivateNetwork
-	ID string `json:"id"`
+	// Required unless the PrivateNetwork is managed
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// DeletionPolicy is what happens to the private NICs of the servers and the configuration of the nodes
+	// when the PrivateNetwork is deleted
+	// +optional
+	// +kubebuilder:default:=Detach
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Managed makes the controller create the Scaleway private network, instead of referencing an existing one with ID
+	// +optional
+	Managed *PrivateNetworkManaged `json:"managed,omitempty"`
 
 	// Zone is the Zone of the PrivateNetwork
 	// Will default to the SCW_DEFAULT_ZONE env variable
 	// +optional
 	Zone string `json:"zone,omitempty"`
 
+	// Region is the Region of a regional PrivateNetwork, which can attach nodes in all the zones of the region
+	// Mutually exclusive with Zone
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecretRef references the Secret holding the Scaleway credentials used for this PrivateNetwork,
+	// with the same keys as the environment variables, e.g. `SCW_ACCESS_KEY` and `SCW_SECRET_KEY`
+	// Defaults to the credentials of the controller
+	// +optional
+	CredentialsSecretRef *corev1.SecretReference `json:"credentialsSecretRef,omitempty"`
+
 	IPAM *PrivateNetworkIPAM `json:"ipam,omitempty"`
 
 	// Routes are the routes injected in the cluster to this PrivateNetwork
@@ -41,6 +65,41 @@ type PrivateNetworkSpec struct {
 	// +kubebuilder:default:=true
 	Masquerade bool `json:"masquerade,omitempty"`
 
+	// NodeSelector selects the nodes the PrivateNetwork is attached to
+	// Defaults to all the nodes
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// InterfaceName is the template of the name given to the interface on the nodes, e.g. `pn-{{ .Name }}`
+	// The template is rendered with the Name and the ID of the PrivateNetwork
+	// Defaults to the name given by the kernel
+	// +optional
+	InterfaceName string `json:"interfaceName,omitempty"`
+
+	// RequiredForScheduling taints the selected nodes with NoSchedule until their NetworkInterface is Ready
+	// +optional
+	RequiredForScheduling bool `json:"requiredForScheduling,omitempty"`
+
+	// AdvertiseAsNodeAddress adds the address of the nodes in the PrivateNetwork as their preferred InternalIP
+	// +optional
+	AdvertiseAsNodeAddress bool `json:"advertiseAsNodeAddress,omitempty"`
+
+	// HealthCheck configures the probing of the PrivateNetwork from the nodes
+	// +optional
+	HealthCheck *PrivateNetworkHealthCheck `json:"healthCheck,omitempty"`
+
+	// DNS publishes DNS records for the addresses of the nodes in the PrivateNetwork
+	// +optional
+	DNS *PrivateNetworkDNS `json:"dns,omitempty"`
+
+	// IngressRules filters the traffic coming to the nodes from the PrivateNetwork
+	// +optional
+	IngressRules *PrivateNetworkIngressRules `json:"ingressRules,omitempty"`
+
+	// PodAttachment generates a Multus NetworkAttachmentDefinition giving pods an address in the PrivateNetwork
+	// +optional
+	PodAttachment *PrivateNetworkPodAttachment `json:"podAttachment,omitempty"`
+
 	// CIDR is the CIDR of the PrivateNetwork
 	// deprecated
 	CIDR string `json:"cidr,omitempty"`
@@ -52,6 +111,226 @@ type PrivateNetworkRoute struct {
 	Via string `json:"via"`
 }
 
+// +kubebuilder:validation:Enum=ICMP;TCP
+// HealthCheckProtocol represents the protocol used to probe the targets
+type HealthCheckProtocol string
+
+const (
+	// HealthCheckProtocolICMP probes the targets with ICMP echo requests
+	HealthCheckProtocolICMP HealthCheckProtocol = "ICMP"
+	// HealthCheckProtocolTCP probes the targets by opening a TCP connection
+	HealthCheckProtocolTCP HealthCheckProtocol = "TCP"
+)
+
+// PrivateNetworkHealthCheck defines the targets probed from the nodes through the PrivateNetwork
+type PrivateNetworkHealthCheck struct {
+	// Gateways probes the gateways of the routes
+	// +optional
+	Gateways bool `json:"gateways,omitempty"`
+
+	// Peers probes the addresses of the other nodes in the PrivateNetwork
+	// +optional
+	Peers bool `json:"peers,omitempty"`
+
+	// IPs are additional addresses to probe
+	// +optional
+	IPs []string `json:"ips,omitempty"`
+
+	// Protocol is the protocol used to probe the targets
+	// +optional
+	// +kubebuilder:default:=ICMP
+	Protocol HealthCheckProtocol `json:"protocol,omitempty"`
+
+	// Port is the port the TCP probes connect to
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// PeriodSeconds is the duration between two probes
+	// +optional
+	// +kubebuilder:default:=30
+	// +kubebuilder:validation:Minimum=5
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the timeout of a probe
+	// +optional
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Detach;Orphan
+// DeletionPolicy represents what happens to the attachments of a PrivateNetwork when it is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDetach deletes the private NICs and tears down the links on the nodes
+	DeletionPolicyDetach DeletionPolicy = "Detach"
+	// DeletionPolicyOrphan leaves the private NICs and the configuration of the nodes in place,
+	// to be adopted again when the PrivateNetwork is created again
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// +kubebuilder:validation:Enum=Delete;Retain
+// ManagedDeletionPolicy represents what happens to a managed Scaleway private network when the PrivateNetwork is deleted
+type ManagedDeletionPolicy string
+
+const (
+	// ManagedDeletionPolicyDelete deletes the Scaleway private network
+	ManagedDeletionPolicyDelete ManagedDeletionPolicy = "Delete"
+	// ManagedDeletionPolicyRetain keeps the Scaleway private network
+	ManagedDeletionPolicyRetain ManagedDeletionPolicy = "Retain"
+)
+
+// PrivateNetworkManaged defines the Scaleway private network created by the controller
+type PrivateNetworkManaged struct {
+	// Name is the name of the Scaleway private network
+	// Defaults to the name of the PrivateNetwork
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Tags are the tags of the Scaleway private network, in addition to the ones set by the controller
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// ProjectID is the project the Scaleway private network is created in
+	// Defaults to the project of the credentials
+	// +optional
+	ProjectID string `json:"projectID,omitempty"`
+
+	// DeletionPolicy is what happens to the Scaleway private network when the PrivateNetwork is deleted
+	// +optional
+	// +kubebuilder:default:=Delete
+	DeletionPolicy ManagedDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// PrivateNetworkDNS defines the DNS records published for the nodes of the PrivateNetwork
+type PrivateNetworkDNS struct {
+	// Domain is the domain of the records, every node gets a `<node>.<private network>.<domain>` record
+	Domain string `json:"domain"`
+
+	// TTL is the TTL of the records
+	// +optional
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum=0
+	TTL int32 `json:"ttl,omitempty"`
+
+	// ConfigMap publishes the records in a ConfigMap, in the format of the CoreDNS hosts plugin
+	// +optional
+	ConfigMap *PrivateNetworkDNSConfigMap `json:"configMap,omitempty"`
+
+	// RFC2136 publishes the records to a DNS server with dynamic updates
+	// +optional
+	RFC2136 *PrivateNetworkDNSRFC2136 `json:"rfc2136,omitempty"`
+}
+
+// PrivateNetworkDNSConfigMap defines the ConfigMap holding the records
+type PrivateNetworkDNSConfigMap struct {
+	// Name is the name of the ConfigMap
+	// Defaults to `<private network>-hosts`
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Namespace is the namespace of the ConfigMap
+	// +optional
+	// +kubebuilder:default:=kube-system
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PrivateNetworkDNSRFC2136 defines the DNS server the records are sent to
+type PrivateNetworkDNSRFC2136 struct {
+	// Server is the address of the DNS server, e.g. `192.168.0.2:53`
+	Server string `json:"server"`
+
+	// Zone is the zone updated on the DNS server, the domain must be in it
+	Zone string `json:"zone"`
+
+	// TSIGKeyName is the name of the TSIG key signing the updates
+	// +optional
+	TSIGKeyName string `json:"tsigKeyName,omitempty"`
+
+	// TSIGSecretRef references the Secret holding the base64 encoded HMAC-SHA256 secret of the TSIG key in its `secret` key
+	// +optional
+	TSIGSecretRef *corev1.SecretReference `json:"tsigSecretRef,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=TCP;UDP;ICMP
+// IngressProtocol represents the protocol of an ingress rule
+type IngressProtocol string
+
+const (
+	// IngressProtocolTCP matches the TCP traffic
+	IngressProtocolTCP IngressProtocol = "TCP"
+	// IngressProtocolUDP matches the UDP traffic
+	IngressProtocolUDP IngressProtocol = "UDP"
+	// IngressProtocolICMP matches the ICMP traffic
+	IngressProtocolICMP IngressProtocol = "ICMP"
+)
+
+// PrivateNetworkIngressRules defines the traffic allowed to the nodes from the PrivateNetwork
+type PrivateNetworkIngressRules struct {
+	// DefaultDeny drops the traffic not allowed by a rule, the replies to the traffic
+	// initiated by the nodes are always allowed
+	// +optional
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+
+	// Rules are the allowed traffic
+	// +optional
+	Rules []PrivateNetworkIngressRule `json:"rules,omitempty"`
+}
+
+// PrivateNetworkIngressRule defines traffic allowed to the nodes
+type PrivateNetworkIngressRule struct {
+	// From are the allowed source CIDRs
+	// Defaults to all the sources
+	// +optional
+	From []string `json:"from,omitempty"`
+
+	// Protocol is the allowed protocol
+	// Defaults to all the protocols
+	// +optional
+	Protocol IngressProtocol `json:"protocol,omitempty"`
+
+	// Ports are the allowed destination ports, only with the TCP and UDP protocols
+	// Defaults to all the ports
+	// +optional
+	Ports []int32 `json:"ports,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=macvlan;ipvlan
+// PodAttachmentMode represents the CNI plugin attaching the pods to the private link
+type PodAttachmentMode string
+
+const (
+	// PodAttachmentModeMacvlan attaches the pods with a macvlan interface
+	PodAttachmentModeMacvlan PodAttachmentMode = "macvlan"
+	// PodAttachmentModeIPvlan attaches the pods with an ipvlan interface
+	PodAttachmentModeIPvlan PodAttachmentMode = "ipvlan"
+)
+
+// PrivateNetworkPodAttachment defines the attachment of the pods to the PrivateNetwork
+type PrivateNetworkPodAttachment struct {
+	// Mode is the CNI plugin used to attach the pods to the private link
+	// +optional
+	// +kubebuilder:default:=macvlan
+	Mode PodAttachmentMode `json:"mode,omitempty"`
+
+	// Namespace is the namespace of the generated NetworkAttachmentDefinition
+	// +optional
+	// +kubebuilder:default:=default
+	Namespace string `json:"namespace,omitempty"`
+
+	// CIDR is the range of the static CIDR the pods get their address from
+	// It must not overlap the available ranges of the nodes
+	CIDR string `json:"cidr"`
+
+	// NodePrefixLength is the length of the prefix carved out of the CIDR for the pods of each node
+	// +optional
+	// +kubebuilder:default:=26
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	NodePrefixLength int32 `json:"nodePrefixLength,omitempty"`
+}
+
 // +kubebuilder:validation:Enum=DHCP;Static
 // IPAMType represents a type of IPAM
 type IPAMType string
@@ -69,6 +348,12 @@ type PrivateNetworkIPAMStatic struct {
 	// AvailableRanges allows to restrict which ranges of addresses should be used when choosing an IP address
 	// Defaults to the whole CIDR
 	AvailableRanges []string `json:"availableRanges,omitempty"`
+	// PodCIDRSize is the length of the prefix carved out of the CIDR for the pods of each node,
+	// routed through the address of the node by the other nodes
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=32
+	PodCIDRSize int32 `json:"podCIDRSize,omitempty"`
 }
 
 // PrivateNetworkIPAM defines the IPAM for the PrivateNetwork
@@ -79,12 +364,33 @@ type PrivateNetworkIPAM struct {
 
 // PrivateNetworkStatus defines the observed state of PrivateNetwork
 type PrivateNetworkStatus struct {
+	// ID is the ID of the Scaleway private network created by the controller
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// DNSRecords are the records sent to the DNS server, as `<name> <address>`
+	// +optional
+	DNSRecords []string `json:"dnsRecords,omitempty"`
+
+	// DNSServer is the DNS server the records in DNSRecords were sent to
+	// +optional
+	DNSServer *PrivateNetworkDNSRFC2136 `json:"dnsServer,omitempty"`
+
+	// Conditions are the conditions of the PrivateNetwork
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// PrivateNetworkCredentialsValid is true when the Scaleway credentials of the PrivateNetwork can be used
+	PrivateNetworkCredentialsValid ConditionType = "CredentialsValid"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster,shortName=pn;privnet;privatenet;privatenetwork
 // +kubebuilder:printcolumn:name="id",type="string",JSONPath=".spec.id"
+// +kubebuilder:printcolumn:name="managed id",type="string",JSONPath=".status.id"
 // +kubebuilder:printcolumn:name="ipam type",type="string",JSONPath=".spec.ipam.type"
 
 // PrivateNetwork is the Schema for the privatenetworks API
@@ -21,16 +21,34 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
@@ -101,6 +119,13 @@ func (in *NetworkInterfaceSpec) DeepCopy() *NetworkInterfaceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceStatus) DeepCopyInto(out *NetworkInterfaceStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceStatus.
@@ -119,7 +144,7 @@ func (in *PrivateNetwork) DeepCopyInto(out *PrivateNetwork) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetwork.
@@ -140,6 +165,86 @@ func (in *PrivateNetwork) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkDNS) DeepCopyInto(out *PrivateNetworkDNS) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(PrivateNetworkDNSConfigMap)
+		**out = **in
+	}
+	if in.RFC2136 != nil {
+		in, out := &in.RFC2136, &out.RFC2136
+		*out = new(PrivateNetworkDNSRFC2136)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkDNS.
+func (in *PrivateNetworkDNS) DeepCopy() *PrivateNetworkDNS {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkDNS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkDNSConfigMap) DeepCopyInto(out *PrivateNetworkDNSConfigMap) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkDNSConfigMap.
+func (in *PrivateNetworkDNSConfigMap) DeepCopy() *PrivateNetworkDNSConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkDNSConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkDNSRFC2136) DeepCopyInto(out *PrivateNetworkDNSRFC2136) {
+	*out = *in
+	if in.TSIGSecretRef != nil {
+		in, out := &in.TSIGSecretRef, &out.TSIGSecretRef
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkDNSRFC2136.
+func (in *PrivateNetworkDNSRFC2136) DeepCopy() *PrivateNetworkDNSRFC2136 {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkDNSRFC2136)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkHealthCheck) DeepCopyInto(out *PrivateNetworkHealthCheck) {
+	*out = *in
+	if in.IPs != nil {
+		in, out := &in.IPs, &out.IPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkHealthCheck.
+func (in *PrivateNetworkHealthCheck) DeepCopy() *PrivateNetworkHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkIPAM) DeepCopyInto(out *PrivateNetworkIPAM) {
 	*out = *in
@@ -180,6 +285,53 @@ func (in *PrivateNetworkIPAMStatic) DeepCopy() *PrivateNetworkIPAMStatic {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkIngressRule) DeepCopyInto(out *PrivateNetworkIngressRule) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkIngressRule.
+func (in *PrivateNetworkIngressRule) DeepCopy() *PrivateNetworkIngressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkIngressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkIngressRules) DeepCopyInto(out *PrivateNetworkIngressRules) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PrivateNetworkIngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkIngressRules.
+func (in *PrivateNetworkIngressRules) DeepCopy() *PrivateNetworkIngressRules {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkIngressRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkList) DeepCopyInto(out *PrivateNetworkList) {
 	*out = *in
@@ -212,6 +364,41 @@ func (in *PrivateNetworkList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkManaged) DeepCopyInto(out *PrivateNetworkManaged) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkManaged.
+func (in *PrivateNetworkManaged) DeepCopy() *PrivateNetworkManaged {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkManaged)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkPodAttachment) DeepCopyInto(out *PrivateNetworkPodAttachment) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkPodAttachment.
+func (in *PrivateNetworkPodAttachment) DeepCopy() *PrivateNetworkPodAttachment {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkPodAttachment)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkRoute) DeepCopyInto(out *PrivateNetworkRoute) {
 	*out = *in
@@ -230,6 +417,16 @@ func (in *PrivateNetworkRoute) DeepCopy() *PrivateNetworkRoute {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 	*out = *in
+	if in.Managed != nil {
+		in, out := &in.Managed, &out.Managed
+		*out = new(PrivateNetworkManaged)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
 	if in.IPAM != nil {
 		in, out := &in.IPAM, &out.IPAM
 		*out = new(PrivateNetworkIPAM)
@@ -240,6 +437,31 @@ func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 		*out = make([]PrivateNetworkRoute, len(*in))
 		copy(*out, *in)
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(PrivateNetworkHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(PrivateNetworkDNS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IngressRules != nil {
+		in, out := &in.IngressRules, &out.IngressRules
+		*out = new(PrivateNetworkIngressRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodAttachment != nil {
+		in, out := &in.PodAttachment, &out.PodAttachment
+		*out = new(PrivateNetworkPodAttachment)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkSpec.
@@ -255,6 +477,23 @@ func (in *PrivateNetworkSpec) DeepCopy() *PrivateNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkStatus) DeepCopyInto(out *PrivateNetworkStatus) {
 	*out = *in
+	if in.DNSRecords != nil {
+		in, out := &in.DNSRecords, &out.DNSRecords
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSServer != nil {
+		in, out := &in.DNSServer, &out.DNSServer
+		*out = new(PrivateNetworkDNSRFC2136)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkStatus.
@@ -0,0 +1,163 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cni-ipam is a CNI IPAM plugin giving containers an address in a PrivateNetwork,
+// allocated by the node daemon
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/podipam"
+)
+
+const (
+	// errCodeInvalidEnv is the CNI error code of invalid environment variables
+	errCodeInvalidEnv = 4
+	// errCodeInvalidConfig is the CNI error code of an invalid network configuration
+	errCodeInvalidConfig = 7
+	// errCodeTryAgainLater is the CNI error code of a transient error
+	errCodeTryAgainLater = 11
+)
+
+var supportedVersions = []string{"0.3.0", "0.3.1", "0.4.0"}
+
+// netConf is the network configuration given on stdin
+type netConf struct {
+	CNIVersion string `json:"cniVersion"`
+	Name       string `json:"name"`
+	IPAM       struct {
+		Type           string `json:"type"`
+		PrivateNetwork string `json:"privateNetwork"`
+		Socket         string `json:"socket,omitempty"`
+	} `json:"ipam"`
+}
+
+type cniIP struct {
+	Version string `json:"version"`
+	Address string `json:"address"`
+}
+
+type cniRoute struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+type cniResult struct {
+	CNIVersion string     `json:"cniVersion"`
+	IPs        []cniIP    `json:"ips"`
+	Routes     []cniRoute `json:"routes,omitempty"`
+}
+
+type cniError struct {
+	CNIVersion string `json:"cniVersion"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+}
+
+type cniVersions struct {
+	CNIVersion        string   `json:"cniVersion"`
+	SupportedVersions []string `json:"supportedVersions"`
+}
+
+func main() {
+	cniVersion := supportedVersions[len(supportedVersions)-1]
+	code, err := run(&cniVersion)
+	if err != nil {
+		_ = json.NewEncoder(os.Stdout).Encode(cniError{
+			CNIVersion: cniVersion,
+			Code:       code,
+			Msg:        err.Error(),
+		})
+		os.Exit(1)
+	}
+}
+
+// run runs the CNI command, and returns the CNI error code on failure
+func run(cniVersion *string) (int, error) {
+	command := os.Getenv("CNI_COMMAND")
+	if command == "VERSION" {
+		return 0, json.NewEncoder(os.Stdout).Encode(cniVersions{
+			CNIVersion:        *cniVersion,
+			SupportedVersions: supportedVersions,
+		})
+	}
+
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return errCodeInvalidConfig, fmt.Errorf("unable to read network configuration: %w", err)
+	}
+	conf := netConf{}
+	err = json.Unmarshal(stdin, &conf)
+	if err != nil {
+		return errCodeInvalidConfig, fmt.Errorf("unable to decode network configuration: %w", err)
+	}
+	if conf.CNIVersion != "" {
+		*cniVersion = conf.CNIVersion
+	}
+	if conf.IPAM.PrivateNetwork == "" {
+		return errCodeInvalidConfig, fmt.Errorf("ipam.privateNetwork is required")
+	}
+
+	socket := conf.IPAM.Socket
+	if socket == "" {
+		socket = podipam.DefaultSocketPath
+	}
+	client := podipam.NewClient(socket)
+	req := podipam.Request{
+		PrivateNetwork: conf.IPAM.PrivateNetwork,
+		ContainerID:    os.Getenv("CNI_CONTAINERID"),
+		IfName:         os.Getenv("CNI_IFNAME"),
+	}
+
+	switch command {
+	case "ADD":
+		allocation, err := client.Allocate(req)
+		if err != nil {
+			return errCodeTryAgainLater, err
+		}
+		result := cniResult{
+			CNIVersion: *cniVersion,
+			IPs: []cniIP{{
+				Version: "4",
+				Address: allocation.Address,
+			}},
+		}
+		for _, route := range allocation.Routes {
+			result.Routes = append(result.Routes, cniRoute{
+				Dst: route.Dst,
+				GW:  route.Gw,
+			})
+		}
+		return 0, json.NewEncoder(os.Stdout).Encode(result)
+	case "DEL":
+		// DEL is best effort, failing it would block the removal of the container
+		// while the node daemon is down; the address is then leaked in the store
+		err := client.Release(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to release address of container %s: %s\n", req.ContainerID, err)
+		}
+		return 0, nil
+	case "CHECK":
+		// the address of the container is checked by the main plugin
+		return 0, nil
+	default:
+		return errCodeInvalidEnv, fmt.Errorf("unknown CNI_COMMAND %q", command)
+	}
+}
@@ -17,14 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
 	goipam "github.com/metal-stack/go-ipam"
-	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -32,10 +34,13 @@ import (
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/controllers"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/health"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/metrics"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -46,6 +51,8 @@ var (
 	defaultCmName        = "scaleway-k8s-vpc-ipam"
 	defaultCmNamespace   = "default"
 	cacheUpdateFrequency = time.Minute * 20
+	defaultSweepInterval = time.Minute * 10
+	apiCheckInterval     = time.Minute
 )
 
 func init() {
@@ -57,34 +64,75 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
+	var clusterID string
+	var sweepInterval time.Duration
+	var attachmentWorkers int
+	var credentialsDir string
+	var credentialsReloadInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the health and readiness probes endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&clusterID, "cluster-id", os.Getenv("CLUSTER_ID"),
+		"The ID used to tag the private NICs created by this controller. "+
+			"Defaults to the UID of the kube-system namespace.")
+	flag.DurationVar(&sweepInterval, "sweep-interval", defaultSweepInterval, "The interval between two sweeps of orphaned private NICs.")
+	flag.IntVar(&attachmentWorkers, "attachment-workers", 5, "The number of nodes attached to private networks concurrently.")
+	flag.StringVar(&credentialsDir, "credentials-dir", "/etc/scaleway-k8s-vpc/credentials",
+		"The directory the Scaleway credentials Secret is mounted in, the environment is used if it's not mounted.")
+	flag.DurationVar(&credentialsReloadInterval, "credentials-reload-interval", time.Minute, "The interval between two checks of the mounted credentials.")
 	klog.InitFlags(nil)
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "be46b6df.scaleway.com",
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "be46b6df.scaleway.com",
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	scwClient, err := scw.NewClient(
-		scw.WithEnv(),
+	scwOptions := []scw.ClientOption{
 		scw.WithUserAgent("scaleway-k8s-vpc"),
-	)
-	if err != nil {
-		setupLog.Error(err, "unable to init scaleway client")
+		scw.WithHTTPClient(metrics.NewScalewayHTTPClient()),
+	}
+	scwClients := &controllers.ScalewayClients{
+		Client:  mgr.GetAPIReader(),
+		Options: scwOptions,
+	}
+	// the controllers start without credentials until they are valid, instead of crashing the manager
+	credentialsLoader := &controllers.CredentialsLoader{
+		Log:      ctrl.Log.WithName("credentials"),
+		Clients:  scwClients,
+		Dir:      credentialsDir,
+		Interval: credentialsReloadInterval,
+	}
+	if err := credentialsLoader.Load(); err != nil {
+		setupLog.Error(err, "unable to load scaleway credentials, retrying in the background")
+	}
+	if err := mgr.Add(credentialsLoader); err != nil {
+		setupLog.Error(err, "unable to add scaleway credentials loader")
+		os.Exit(1)
+	}
+
+	if clusterID == "" {
+		ns := &corev1.Namespace{}
+		err := mgr.GetAPIReader().Get(context.Background(), types.NamespacedName{Name: "kube-system"}, ns)
+		if err != nil {
+			setupLog.Error(err, "unable to get cluster ID from the kube-system namespace")
+			os.Exit(1)
+		}
+		clusterID = string(ns.UID)
 	}
 
 	stopCh := ctrl.SetupSignalHandler()
@@ -108,29 +156,93 @@ func main() {
 	}
 	ipam := goipam.NewWithStorage(cmIPAM)
 
+	metrics.RegisterController(&metrics.Collector{
+		Client: mgr.GetClient(),
+		IPAM:   ipam,
+	})
+
 	if err = (&controllers.PrivateNetworkReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("PrivateNetwork"),
-		Scheme:      mgr.GetScheme(),
-		IPAM:        ipam,
-		InstanceAPI: instance.NewAPI(scwClient),
-		VpcAPI:      vpc.NewAPI(scwClient),
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("PrivateNetwork"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+		IPAM:      ipam,
+		Scaleway:  scwClients,
+		ClusterID: clusterID,
+		APIReader: mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PrivateNetwork")
 		os.Exit(1)
 	}
+	if err = (&controllers.AttachmentReconciler{
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("Attachment"),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+		IPAM:                    ipam,
+		Scaleway:                scwClients,
+		ClusterID:               clusterID,
+		MaxConcurrentReconciles: attachmentWorkers,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Attachment")
+		os.Exit(1)
+	}
 	if err = (&controllers.NetworkInterfaceReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
-		Scheme:      mgr.GetScheme(),
-		IPAM:        ipam,
-		InstanceAPI: instance.NewAPI(scwClient),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+		IPAM:     ipam,
+		Scaleway: scwClients,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
+	if err = (&controllers.ServiceReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Service"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
+		IPAM:     ipam,
+		Cache:    mgr.GetCache(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Service")
+		os.Exit(1)
+	}
+	if err = mgr.Add(&controllers.PrivateNICSweeper{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("sweeper"),
+		Scaleway:  scwClients,
+		ClusterID: clusterID,
+		Interval:  sweepInterval,
+	}); err != nil {
+		setupLog.Error(err, "unable to add private nic sweeper")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("ipam", cmIPAM.Check); err != nil {
+		setupLog.Error(err, "unable to add ipam readiness check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("scaleway", health.CachedChecker(apiCheckInterval, func() error {
+		scwClient := scwClients.Default()
+		if scwClient == nil {
+			return fmt.Errorf("scaleway credentials not loaded")
+		}
+		_, err := vpc.NewAPI(scwClient).ListPrivateNetworks(&vpc.ListPrivateNetworksRequest{
+			PageSize: scw.Uint32Ptr(1),
+		})
+		return err
+	})); err != nil {
+		setupLog.Error(err, "unable to add scaleway readiness check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(stopCh); err != nil {
 		setupLog.Error(err, "problem running manager")
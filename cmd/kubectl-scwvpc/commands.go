@@ -0,0 +1,480 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/ipam"
+)
+
+// parseArgs parses the flags of a command, allowing them after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// privateNetworks returns the given PrivateNetworks, or all of them if none is given
+func (o *options) privateNetworks(ctx context.Context, names []string) ([]vpcv1alpha1.PrivateNetwork, error) {
+	if len(names) == 0 {
+		pnsList := &vpcv1alpha1.PrivateNetworkList{}
+		err := o.client.List(ctx, pnsList)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(pnsList.Items, func(i, j int) bool {
+			return pnsList.Items[i].Name < pnsList.Items[j].Name
+		})
+		return pnsList.Items, nil
+	}
+
+	pns := []vpcv1alpha1.PrivateNetwork{}
+	for _, name := range names {
+		pn := vpcv1alpha1.PrivateNetwork{}
+		err := o.client.Get(ctx, types.NamespacedName{Name: name}, &pn)
+		if err != nil {
+			return nil, err
+		}
+		pns = append(pns, pn)
+	}
+	return pns, nil
+}
+
+// networkInterfaces returns the NetworkInterfaces of the PrivateNetwork, sorted by node
+func (o *options) networkInterfaces(ctx context.Context, pnName string) ([]vpcv1alpha1.NetworkInterface, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := o.client.List(ctx, nicsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pnName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nicsList.Items, func(i, j int) bool {
+		return nicsList.Items[i].Spec.NodeName < nicsList.Items[j].Spec.NodeName
+	})
+	return nicsList.Items, nil
+}
+
+// ipamFor returns the IPAM backed by the IPAM ConfigMap, and the storage it uses
+func (o *options) ipamFor() (goipam.Ipamer, *ipam.ConfigMapIPAM, func(), error) {
+	stopCh := make(chan struct{})
+	cmIPAM, err := ipam.NewConfigMapIPAM(types.NamespacedName{
+		Name:      o.cmName,
+		Namespace: o.cmNamespace,
+	}, stopCh)
+	if err != nil {
+		close(stopCh)
+		return nil, nil, nil, err
+	}
+	return goipam.NewWithStorage(cmIPAM), cmIPAM, func() { close(stopCh) }, nil
+}
+
+// prefixesOf returns the prefixes managed by the IPAM for the PrivateNetwork
+func prefixesOf(pn *vpcv1alpha1.PrivateNetwork) []string {
+	if pn.Spec.CIDR != "" {
+		// deprecated
+		return []string{pn.Spec.CIDR}
+	}
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeStatic || pn.Spec.IPAM.Static == nil {
+		return nil
+	}
+	if len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
+		return pn.Spec.IPAM.Static.AvailableRanges
+	}
+	return []string{pn.Spec.IPAM.Static.CIDR}
+}
+
+// prefixContaining returns the prefix of the PrivateNetwork containing the IP
+func prefixContaining(pn *vpcv1alpha1.PrivateNetwork, ip net.IP) (string, error) {
+	for _, prefix := range prefixesOf(pn) {
+		_, ipnet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return "", err
+		}
+		if ipnet.Contains(ip) {
+			return prefix, nil
+		}
+	}
+	return "", fmt.Errorf("ip %s is not managed by the IPAM of private network %s", ip, pn.Name)
+}
+
+// addressOf returns the IP of the NetworkInterface, without the mask
+func addressOf(nic *vpcv1alpha1.NetworkInterface) string {
+	address := nic.Status.Address
+	if address == "" {
+		// deprecated
+		address = nic.Spec.Address
+	}
+	return strings.Split(address, "/")[0]
+}
+
+func runAttachments(o *options, args []string) error {
+	ctx := context.Background()
+	pns, err := o.privateNetworks(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	for i, pn := range pns {
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("PrivateNetwork %s (%s)\n", pn.Name, pn.PrivateNetworkID())
+
+		nics, err := o.networkInterfaces(ctx, pn.Name)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tNETWORKINTERFACE\tPHASE\tADDRESS\tLINK\tMAC\tPRIVATE NIC")
+		for _, nic := range nics {
+			phase := string(nic.Status.Phase)
+			if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+				phase = "Deleting"
+			}
+			address := nic.Status.Address
+			if address == "" {
+				address = nic.Spec.Address
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", nic.Spec.NodeName, nic.Name, phase, address, nic.Status.LinkName, nic.Status.MacAddress, nic.Spec.ID)
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+func runIPAM(o *options, args []string) error {
+	ctx := context.Background()
+	pns, err := o.privateNetworks(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	_, cmIPAM, stop, err := o.ipamFor()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for i, pn := range pns {
+		if i != 0 {
+			fmt.Println()
+		}
+		prefixes := prefixesOf(&pn)
+		if len(prefixes) == 0 {
+			fmt.Printf("PrivateNetwork %s: IPAM not managed by the controller\n", pn.Name)
+			continue
+		}
+		fmt.Printf("PrivateNetwork %s\n", pn.Name)
+
+		nics, err := o.networkInterfaces(ctx, pn.Name)
+		if err != nil {
+			return err
+		}
+		owners := make(map[string]string)
+		for _, nic := range nics {
+			owners[addressOf(&nic)] = nic.Name
+		}
+
+		// the allocations are computed on a snapshot, to never modify the ConfigMap
+		snapshot, err := ipam.NewSnapshot(cmIPAM)
+		if err != nil {
+			return err
+		}
+		snapshotIPAM := goipam.NewWithStorage(snapshot)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "PREFIX\tIP\tOWNER")
+		for _, cidr := range prefixes {
+			prefix := snapshotIPAM.PrefixFrom(cidr)
+			if prefix == nil {
+				fmt.Fprintf(w, "%s\t<none>\t<prefix not created yet>\n", cidr)
+				continue
+			}
+			usage := prefix.Usage()
+			fmt.Fprintf(w, "%s\t%d/%d used\t\n", cidr, usage.AcquiredIPs, usage.AvailableIPs)
+
+			acquired, err := acquiredIPs(snapshotIPAM, cidr)
+			if err != nil {
+				return err
+			}
+			for _, ip := range acquired {
+				owner, ok := owners[ip]
+				if !ok {
+					owner = "<reserved or leaked>"
+				}
+				fmt.Fprintf(w, "\t%s\t%s\n", ip, owner)
+			}
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+// acquiredIPs returns the acquired IPs of the prefix, excluding the network and broadcast addresses.
+// The given IPAM must be backed by a snapshot as the free IPs are acquired to be found.
+func acquiredIPs(snapshotIPAM goipam.Ipamer, cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	broadcast := make(net.IP, len(ipnet.IP))
+	for i := range ipnet.IP {
+		broadcast[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+
+	acquired := []string{}
+	for ip = nextIP(ipnet.IP.Mask(ipnet.Mask)); ipnet.Contains(ip) && !ip.Equal(broadcast); ip = nextIP(ip) {
+		_, err := snapshotIPAM.AcquireSpecificIP(cidr, ip.String())
+		if err != nil {
+			acquired = append(acquired, ip.String())
+		}
+	}
+	return acquired, nil
+}
+
+// nextIP returns the IP following the given one
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func runFreeIPs(o *options, args []string) error {
+	fs := flag.NewFlagSet("free-ips", flag.ExitOnError)
+	count := fs.Int("count", 10, "The number of free IPs to list.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["free-ips"].usage)
+	}
+
+	ctx := context.Background()
+	pns, err := o.privateNetworks(ctx, args)
+	if err != nil {
+		return err
+	}
+	pn := pns[0]
+
+	prefixes := prefixesOf(&pn)
+	if len(prefixes) == 0 {
+		return fmt.Errorf("the IPAM of private network %s is not managed by the controller", pn.Name)
+	}
+
+	_, cmIPAM, stop, err := o.ipamFor()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	snapshot, err := ipam.NewSnapshot(cmIPAM)
+	if err != nil {
+		return err
+	}
+	snapshotIPAM := goipam.NewWithStorage(snapshot)
+
+	found := 0
+	for _, cidr := range prefixes {
+		prefix := snapshotIPAM.PrefixFrom(cidr)
+		if prefix == nil {
+			// the controller creates the prefix on the first allocation
+			_, err := snapshotIPAM.NewPrefix(cidr)
+			if err != nil {
+				return err
+			}
+		}
+		for found < *count {
+			ip, err := snapshotIPAM.AcquireIP(cidr)
+			if err != nil {
+				break
+			}
+			fmt.Printf("%s\t%s\n", ip.IP.String(), cidr)
+			found++
+		}
+	}
+	if found == 0 {
+		return fmt.Errorf("no free IP in private network %s", pn.Name)
+	}
+	return nil
+}
+
+func runReserveIP(o *options, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s", commands["reserve-ip"].usage)
+	}
+
+	ctx := context.Background()
+	pns, err := o.privateNetworks(ctx, args[:1])
+	if err != nil {
+		return err
+	}
+	pn := pns[0]
+
+	ip := net.ParseIP(args[1])
+	if ip == nil {
+		return fmt.Errorf("invalid ip %s", args[1])
+	}
+	cidr, err := prefixContaining(&pn, ip)
+	if err != nil {
+		return err
+	}
+
+	ipamer, _, stop, err := o.ipamFor()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if ipamer.PrefixFrom(cidr) == nil {
+		_, err := ipamer.NewPrefix(cidr)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = ipamer.AcquireSpecificIP(cidr, ip.String())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reserved ip %s in %s\n", ip, cidr)
+	return nil
+}
+
+func runReleaseIP(o *options, args []string) error {
+	fs := flag.NewFlagSet("release-ip", flag.ExitOnError)
+	force := fs.Bool("force", false, "Release the IP even if a NetworkInterface uses it.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s", commands["release-ip"].usage)
+	}
+
+	ctx := context.Background()
+	pns, err := o.privateNetworks(ctx, args[:1])
+	if err != nil {
+		return err
+	}
+	pn := pns[0]
+
+	ip := net.ParseIP(args[1])
+	if ip == nil {
+		return fmt.Errorf("invalid ip %s", args[1])
+	}
+	cidr, err := prefixContaining(&pn, ip)
+	if err != nil {
+		return err
+	}
+
+	nics, err := o.networkInterfaces(ctx, pn.Name)
+	if err != nil {
+		return err
+	}
+	for _, nic := range nics {
+		if addressOf(&nic) == ip.String() && !*force {
+			return fmt.Errorf("ip %s is used by networkInterface %s, use --force to release it anyway", ip, nic.Name)
+		}
+	}
+
+	ipamer, _, stop, err := o.ipamFor()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	err = ipamer.ReleaseIPFromPrefix(cidr, ip.String())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Released ip %s from %s\n", ip, cidr)
+	return nil
+}
+
+func runReattach(o *options, args []string) error {
+	fs := flag.NewFlagSet("reattach", flag.ExitOnError)
+	pnName := fs.String("private-network", "", "Only reattach this private network.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", commands["reattach"].usage)
+	}
+
+	labels := client.MatchingLabels{
+		constants.NodeLabel: args[0],
+	}
+	if *pnName != "" {
+		labels[constants.PrivateNetworkLabel] = *pnName
+	}
+
+	ctx := context.Background()
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = o.client.List(ctx, nicsList, labels)
+	if err != nil {
+		return err
+	}
+	if len(nicsList.Items) == 0 {
+		return fmt.Errorf("no networkInterface found on node %s", args[0])
+	}
+
+	// the NetworkInterfaces are recreated by the controller once deleted
+	for _, nic := range nicsList.Items {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		err := o.client.Delete(ctx, &nic)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted networkInterface %s of private network %s, it will be recreated by the controller\n", nic.Name, nic.Labels[constants.PrivateNetworkLabel])
+	}
+	return nil
+}
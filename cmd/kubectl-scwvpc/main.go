@@ -0,0 +1,137 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var (
+	scheme = runtime.NewScheme()
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	_ = vpcv1alpha1.AddToScheme(scheme)
+}
+
+// command is a subcommand of the plugin
+type command struct {
+	usage string
+	help  string
+	run   func(o *options, args []string) error
+}
+
+// commands are the subcommands by name, set in init as they refer to it for their usage
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"attachments": {
+			usage: "attachments [private-network...]",
+			help:  "Show the nodes attached to the private networks",
+			run:   runAttachments,
+		},
+		"ipam": {
+			usage: "ipam [private-network...]",
+			help:  "Show the IPAM allocations of the private networks",
+			run:   runIPAM,
+		},
+		"free-ips": {
+			usage: "free-ips <private-network> [--count N]",
+			help:  "List the next free IPs of a private network",
+			run:   runFreeIPs,
+		},
+		"reserve-ip": {
+			usage: "reserve-ip <private-network> <ip>",
+			help:  "Reserve an IP so that it's never given to a node",
+			run:   runReserveIP,
+		},
+		"release-ip": {
+			usage: "release-ip <private-network> <ip> [--force]",
+			help:  "Release a reserved or leaked IP",
+			run:   runReleaseIP,
+		},
+		"reattach": {
+			usage: "reattach <node> [--private-network name]",
+			help:  "Detach and reattach the private networks of a node",
+			run:   runReattach,
+		},
+	}
+}
+
+var commandsOrder = []string{"attachments", "ipam", "free-ips", "reserve-ip", "release-ip", "reattach"}
+
+// options are the global options of the plugin
+type options struct {
+	client      client.Client
+	cmName      string
+	cmNamespace string
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl scwvpc [flags] <command> [args]\n\nCommands:\n")
+	for _, name := range commandsOrder {
+		fmt.Fprintf(os.Stderr, "  %-45s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	o := &options{}
+	flag.StringVar(&o.cmName, "ipam-configmap", "scaleway-k8s-vpc-ipam", "The name of the IPAM ConfigMap.")
+	flag.StringVar(&o.cmNamespace, "ipam-namespace", "scaleway-k8s-vpc-system", "The namespace of the IPAM ConfigMap.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %s\n", err)
+		os.Exit(1)
+	}
+	o.client = c
+
+	err = cmd.run(o, flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
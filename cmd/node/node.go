@@ -17,20 +17,33 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
 	"github.com/Sh4d1/scaleway-k8s-vpc/nodes"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/health"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/metrics"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/podipam"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	// +kubebuilder:scaffold:imports
 )
@@ -39,7 +52,8 @@ var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
 
-	cacheUpdateFrequency = time.Minute * 20
+	cacheUpdateFrequency  = time.Minute * 20
+	metadataCheckInterval = time.Second * 30
 )
 
 func init() {
@@ -50,24 +64,54 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(os.Args[2:]))
+	}
+
 	var metricsAddr string
+	var probeAddr string
+	var stateFile string
+	var cleanup bool
+	var cleanupOnlyOnUninstall bool
+	var podIPAMSocket string
+	var podIPAMFile string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the health and readiness probes endpoint binds to.")
+	flag.StringVar(&stateFile, "state-file", "/var/lib/scaleway-k8s-vpc/state.json", "The file recording the links configured on the node.")
+	flag.BoolVar(&cleanup, "cleanup", false, "Tear down all the links configured on the node and exit.")
+	flag.BoolVar(&cleanupOnlyOnUninstall, "cleanup-only-on-uninstall", false,
+		"With --cleanup, only tear down the links if the DaemonSet of the pod is deleted, and not on rolling updates.")
+	flag.StringVar(&podIPAMSocket, "pod-ipam-socket", podipam.DefaultSocketPath, "The unix socket the pod IPAM is served on for the CNI IPAM plugin.")
+	flag.StringVar(&podIPAMFile, "pod-ipam-file", "/var/lib/scaleway-k8s-vpc/pod-ipam.json", "The file recording the addresses given to the pods.")
 	klog.InitFlags(nil)
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
 
+	state, err := nodes.LoadState(stateFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load state")
+		os.Exit(1)
+	}
+
+	if cleanup {
+		os.Exit(runCleanup(state, cleanupOnlyOnUninstall))
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     false,
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         false,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	metrics.RegisterNode()
+
 	metadataAPI := instance.NewMetadataAPI()
 	md, err := metadataAPI.GetMetadata()
 	if err != nil {
@@ -92,22 +136,253 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&nodes.NetworkInterfaceReconciler{
+	// remove the links left by a previous instance that are not backed by a NetworkInterface anymore
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = mgr.GetAPIReader().List(context.Background(), nicsList, client.MatchingLabels{
+		constants.NodeLabel: nodeName,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to list networkInterfaces")
+		os.Exit(1)
+	}
+	keep := make(map[string]struct{})
+	for _, nic := range nicsList.Items {
+		keep[nic.Status.MacAddress] = struct{}{}
+	}
+	err = nodes.TearDownLinks(ctrl.Log.WithName("cleanup"), state, nics, keep)
+	if err != nil {
+		setupLog.Error(err, "unable to tear down stale links")
+	}
+
+	reconciler := &nodes.NetworkInterfaceReconciler{
 		Client:      mgr.GetClient(),
 		Log:         ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
 		Scheme:      mgr.GetScheme(),
+		Recorder:    mgr.GetEventRecorderFor("scaleway-k8s-vpc-node"),
 		MetadataAPI: metadataAPI,
 		NodeName:    nodeName,
 		NICs:        nics,
-	}).SetupWithManager(mgr); err != nil {
+		State:       state,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
+	if err = (&nodes.NodeAddressReconciler{
+		NetworkInterfaceReconciler: reconciler,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NodeAddress")
+		os.Exit(1)
+	}
+	if err = (&nodes.ServiceReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Service"),
+		Scheme:   mgr.GetScheme(),
+		NodeName: nodeName,
+		NICs:     nics,
+		State:    state,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Service")
+		os.Exit(1)
+	}
+	if err = mgr.Add(&nodes.Prober{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("prober"),
+		NodeName: nodeName,
+	}); err != nil {
+		setupLog.Error(err, "unable to add prober")
+		os.Exit(1)
+	}
+	podIPAMStore, err := podipam.LoadStore(podIPAMFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load pod ipam")
+		os.Exit(1)
+	}
+	if err = mgr.Add(&nodes.PodIPAMServer{
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("podipam"),
+		NodeName:   nodeName,
+		SocketPath: podIPAMSocket,
+		Store:      podIPAMStore,
+	}); err != nil {
+		setupLog.Error(err, "unable to add pod ipam server")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("metadata", health.CachedChecker(metadataCheckInterval, func() error {
+		_, err := metadataAPI.GetMetadata()
+		return err
+	})); err != nil {
+		setupLog.Error(err, "unable to add metadata readiness check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("netlink", func(_ *http.Request) error {
+		return nics.Check()
+	}); err != nil {
+		setupLog.Error(err, "unable to add netlink readiness check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("networkinterfaces", reconciler.ReadyCheck); err != nil {
+		setupLog.Error(err, "unable to add networkinterfaces readiness check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// runCleanup tears down the links recorded in the state, and returns the exit code
+func runCleanup(state *nodes.State, onlyOnUninstall bool) int {
+	log := ctrl.Log.WithName("cleanup")
+
+	if onlyOnUninstall {
+		uninstalled, err := isUninstalled()
+		if err != nil {
+			log.Error(err, "unable to check if the daemonset is deleted")
+			return 1
+		}
+		if !uninstalled {
+			log.Info("Daemonset not deleted, skipping cleanup")
+			return 0
+		}
+	}
+
+	macs := []string{}
+	for mac := range state.GetLinks() {
+		macs = append(macs, mac)
+	}
+
+	nics, err := nics.NewNICs(macs)
+	if err != nil {
+		log.Error(err, "unable to init nics handler")
+		return 1
+	}
+
+	err = nodes.TearDownLinks(log, state, nics, nil)
+	if err != nil {
+		log.Error(err, "unable to tear down links")
+		return 1
+	}
+	return 0
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get
+
+// isUninstalled returns whether the DaemonSet owning the pod, given by the POD_NAME and POD_NAMESPACE
+// env variables, is deleted
+func isUninstalled() (bool, error) {
+	podName := os.Getenv("POD_NAME")
+	podNamespace := os.Getenv("POD_NAMESPACE")
+	if podName == "" || podNamespace == "" {
+		return false, fmt.Errorf("POD_NAME and POD_NAMESPACE must be set")
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	pod := &corev1.Pod{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: podNamespace, Name: podName}, pod)
+	if err != nil {
+		return false, err
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind != "DaemonSet" {
+			continue
+		}
+		ds := &appsv1.DaemonSet{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: podNamespace, Name: owner.Name}, ds)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return !ds.ObjectMeta.GetDeletionTimestamp().IsZero() || ds.UID != owner.UID, nil
+	}
+	return true, nil
+}
+
+// runDoctor compares the links of the node with its state file and its NetworkInterfaces, and returns the exit code.
+// The NetworkInterfaces are only checked when a kubeconfig is available.
+func runDoctor(args []string) int {
+	var nodeName string
+	var stateFile string
+	var offline bool
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.StringVar(&nodeName, "node-name", os.Getenv("NODE_NAME"), "The name of the node, defaults to the hostname from the metadata.")
+	fs.StringVar(&stateFile, "state-file", "/var/lib/scaleway-k8s-vpc/state.json", "The file recording the links configured on the node.")
+	fs.BoolVar(&offline, "offline", false, "Only run the checks on the node, without the API server.")
+	_ = fs.Parse(args)
+
+	state, err := nodes.LoadState(stateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load state: %s\n", err)
+		return 2
+	}
+
+	metadataAPI := instance.NewMetadataAPI()
+	md, err := metadataAPI.GetMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to fetch Scaleway metadata: %s\n", err)
+		return 2
+	}
+	if nodeName == "" {
+		nodeName = md.Hostname
+	}
+
+	macs := []string{}
+	for _, pn := range md.PrivateNICs {
+		macs = append(macs, pn.MacAddress)
+	}
+
+	nics, err := nics.NewNICs(macs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to init nics handler: %s\n", err)
+		return 2
+	}
+
+	doctor := &nodes.Doctor{
+		MetadataAPI: metadataAPI,
+		NICs:        nics,
+		State:       state,
+		NodeName:    nodeName,
+		Out:         os.Stdout,
+	}
+	if !offline {
+		// the checks on the node still run when the API server can't be reached
+		config, err := ctrl.GetConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to load kubeconfig, skipping the NetworkInterface checks: %s\n", err)
+		} else {
+			doctor.Client, err = client.New(config, client.Options{Scheme: scheme})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to create client: %s\n", err)
+				return 2
+			}
+		}
+	}
+	drift, err := doctor.Run(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to run doctor: %s\n", err)
+		return 2
+	}
+	if drift {
+		fmt.Fprintln(os.Stdout, "\nDrift detected")
+		return 1
+	}
+	fmt.Fprintln(os.Stdout, "\nNo drift detected")
+	return 0
+}
@@ -0,0 +1,636 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/taints"
+)
+
+// AttachmentReconciler reconciles the attachment of a PrivateNetwork to a Node.
+// Each (PrivateNetwork, Node) pair is reconciled independently, so that a failing
+// node does not prevent the PrivateNetwork from being attached to the other ones.
+type AttachmentReconciler struct {
+	client.Client
+	Log                     logr.Logger
+	Scheme                  *runtime.Scheme
+	Recorder                record.EventRecorder
+	IPAM                    goipam.Ipamer
+	Scaleway                *ScalewayClients
+	ClusterID               string
+	MaxConcurrentReconciles int
+}
+
+// attachmentRequest returns the request for the attachment of the PrivateNetwork to the node.
+// As both objects are cluster scoped, the name of the PrivateNetwork is stored as the namespace.
+func attachmentRequest(pnName string, nodeName string) reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: pnName,
+			Name:      nodeName,
+		},
+	}
+}
+
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *AttachmentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("privatenetwork", req.Namespace, "node", req.Name)
+
+	pn := &vpcv1alpha1.PrivateNetwork{}
+	err := r.Get(ctx, types.NamespacedName{Name: req.Namespace}, pn)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "could not get privateNetwork")
+		return ctrl.Result{}, err
+	}
+
+	// the PrivateNetwork deletion is handled by the PrivateNetworkReconciler
+	if !pn.ObjectMeta.GetDeletionTimestamp().IsZero() || !controllerutil.ContainsFinalizer(pn, constants.FinalizerName) {
+		return ctrl.Result{}, nil
+	}
+
+	if pn.PrivateNetworkID() == "" {
+		log.Info("waiting for the managed private network to be created")
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
+			constants.NodeLabel:           req.Name,
+		},
+	)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", req.Name, pn.Name))
+		return ctrl.Result{}, err
+	}
+
+	node := &corev1.Node{}
+	err = r.Get(ctx, types.NamespacedName{Name: req.Name}, node)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "could not get node")
+		return ctrl.Result{}, err
+	}
+
+	selected := false
+	if err == nil {
+		nodeSelector, err := pn.NodeLabelSelector()
+		if err != nil {
+			log.Error(err, "invalid nodeSelector")
+			return ctrl.Result{}, nil
+		}
+		selected = nodeSelector.Matches(labels.Set(node.Labels))
+	}
+
+	if !selected {
+		for _, nic := range nicsList.Items {
+			if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+				err := r.Client.Delete(ctx, &nic)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("failed to delete networkInterface %s", nic.Name))
+					return ctrl.Result{}, err
+				}
+				log.Info(fmt.Sprintf("Successfully deleted networkInterface %s on unselected node %s", nic.Name, req.Name))
+				r.Recorder.Eventf(pn, corev1.EventTypeNormal, events.ReasonDetached, "Detaching unselected node %s", req.Name)
+				r.Recorder.Eventf(events.NodeRef(req.Name), corev1.EventTypeNormal, events.ReasonDetached, "Detaching private network %s", pn.Name)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if pn.Spec.RequiredForScheduling && !hasReadyNetworkInterface(nicsList.Items) {
+		err := r.taintNode(ctx, node)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("could not taint node %s", node.Name))
+			return ctrl.Result{}, err
+		}
+	}
+
+	scwClient, err := r.Scaleway.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		log.Error(err, "unable to get scaleway client")
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+	}
+	instanceAPI := instance.NewAPI(scwClient)
+
+	server, err := getServerFromNode(instanceAPI, node)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
+		r.Recorder.Eventf(pn, corev1.EventTypeWarning, events.ReasonServerNotFound, "Could not get server of node %s: %s", node.Name, err)
+		r.Recorder.Event(events.NodeRef(node.Name), corev1.EventTypeWarning, events.ReasonServerNotFound, err.Error())
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+	}
+
+	if len(nicsList.Items) > 1 {
+		log.Info(fmt.Sprintf("node %s have %d networkInterfaces instead of at most one, only using the oldest one", node.Name, len(nicsList.Items)))
+		nicsList.Items, err = r.rejectDuplicateNetworkInterfaces(ctx, pn, nicsList.Items)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("could not mark duplicate networkInterfaces of node %s as degraded", node.Name))
+			return ctrl.Result{}, err
+		}
+	}
+
+	// a regional private network can be attached to servers in any zone of the region
+	var privateNIC *instance.PrivateNIC
+	err = checkServerZone(scwClient, pn, server)
+	if err == nil {
+		privateNIC, err = r.ensurePrivateNIC(instanceAPI, pn, server, nicsList.Items)
+	}
+	if err != nil {
+		log.Error(err, fmt.Sprintf("unable to get private nic on server %s", server.ID))
+		r.Recorder.Eventf(pn, corev1.EventTypeWarning, events.ReasonPrivateNICUnavailable, "Could not attach node %s: %s", node.Name, err)
+		r.Recorder.Event(events.NodeRef(node.Name), corev1.EventTypeWarning, events.ReasonPrivateNICUnavailable, err.Error())
+		if len(nicsList.Items) == 1 {
+			err := r.setNetworkInterfaceDegraded(ctx, &nicsList.Items[0], err)
+			if err != nil {
+				log.Error(err, "could not patch networkInterface status")
+			}
+		}
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+	}
+
+	if len(nicsList.Items) == 1 {
+		nic := &nicsList.Items[0]
+		if isDuplicateNetworkInterface(nic) {
+			// the NetworkInterface it was a duplicate of was removed
+			err := r.setNetworkInterfaceNotDuplicate(ctx, nic)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("could not patch networkInterface %s status", nic.Name))
+				return ctrl.Result{}, err
+			}
+		}
+		if nic.Spec.ID != privateNIC.ID {
+			log.Info(fmt.Sprintf("private nic %s of networkInterface %s not found on server %s, using private nic %s", nic.Spec.ID, nic.Name, server.ID, privateNIC.ID))
+			err := r.replacePrivateNIC(ctx, nic, privateNIC)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("could not update networkInterface %s", nic.Name))
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonPrivateNICRecreated, "Private nic not found on server %s, replaced by %s", server.ID, privateNIC.ID)
+		}
+		return ctrl.Result{RequeueAfter: DriftCheckDuration}, nil
+	}
+
+	nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
+	if err != nil {
+		log.Error(err, "unable to construct networkInterface from privateNetwork")
+		return ctrl.Result{}, err
+	}
+	nic.Spec.ID = privateNIC.ID
+
+	// staticCIDR is the prefix the address of the networkInterface is acquired from, if any
+	staticCIDR := ""
+	if pn.Spec.CIDR != "" {
+		// deprecated
+		prefix, err := r.IPAM.NewPrefix(pn.Spec.CIDR)
+		if err != nil {
+			log.Error(err, "error creating new prefix")
+			return ctrl.Result{}, err
+		}
+		ip, err := r.IPAM.AcquireIP(prefix.Cidr)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
+			return ctrl.Result{}, err
+		}
+		// TODO have a better idea :D
+		nic.Spec.Address = ip.IP.String() + "/" + strings.Split(prefix.Cidr, "/")[1]
+		staticCIDR = prefix.Cidr
+	}
+
+	err = r.Client.Create(ctx, nic)
+	if err != nil {
+		if staticCIDR != "" {
+			ipamErr := r.IPAM.ReleaseIPFromPrefix(staticCIDR, strings.Split(nic.Spec.Address, "/")[0])
+			if ipamErr != nil {
+				log.Error(ipamErr, fmt.Sprintf("failed to release IP %s", nic.Spec.Address))
+			}
+		}
+		log.Error(err, "could not create networkInterface")
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(nic.DeepCopy())
+	nic.Status.MacAddress = privateNIC.MacAddress
+	nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhasePending
+	err = r.Client.Status().Patch(ctx, nic, patch)
+	if err != nil {
+		log.Error(err, "could not patch networkInterface status")
+		return ctrl.Result{}, err
+	}
+	log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
+	r.Recorder.Eventf(pn, corev1.EventTypeNormal, events.ReasonAttached, "Attached node %s with networkInterface %s", node.Name, nic.Name)
+	r.Recorder.Eventf(events.NodeRef(node.Name), corev1.EventTypeNormal, events.ReasonAttached, "Attached private network %s with networkInterface %s", pn.Name, nic.Name)
+
+	return ctrl.Result{RequeueAfter: DriftCheckDuration}, nil
+}
+
+// ensurePrivateNIC returns the private NIC of the server attached to the PrivateNetwork, creating it if needed.
+// An existing private NIC is only used if it was created by this cluster, if it's already referenced by one of
+// the NetworkInterfaces of the node (created before the private NICs were tagged), or if the PrivateNetwork
+// allows adopting private NICs created out of band.
+func (r *AttachmentReconciler) ensurePrivateNIC(instanceAPI *instance.API, pn *vpcv1alpha1.PrivateNetwork, server *instance.Server, nics []vpcv1alpha1.NetworkInterface) (*instance.PrivateNIC, error) {
+	var privateNIC *instance.PrivateNIC
+	for _, pnic := range server.PrivateNics {
+		if pnic.PrivateNetworkID == pn.PrivateNetworkID() {
+			privateNIC = pnic
+			break
+		}
+	}
+
+	tags := privateNICTags(r.ClusterID, pn.Name)
+
+	if privateNIC == nil {
+		pnicResp, err := instanceAPI.CreatePrivateNIC(&instance.CreatePrivateNICRequest{
+			Zone:             server.Zone,
+			PrivateNetworkID: pn.PrivateNetworkID(),
+			ServerID:         server.ID,
+			Tags:             tags,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create private nic on server %s: %w", server.ID, err)
+		}
+		return pnicResp.PrivateNic, nil
+	}
+
+	if hasTag(privateNIC.Tags, tags[0]) {
+		if !hasTag(privateNIC.Tags, constants.OrphanedTag) {
+			return privateNIC, nil
+		}
+		// left in place when a previous PrivateNetwork was deleted
+		tags := removeTag(privateNIC.Tags, constants.OrphanedTag)
+		pnic, err := instanceAPI.UpdatePrivateNIC(&instance.UpdatePrivateNICRequest{
+			Zone:         server.Zone,
+			ServerID:     server.ID,
+			PrivateNicID: privateNIC.ID,
+			Tags:         &tags,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to adopt orphaned private nic %s on server %s: %w", privateNIC.ID, server.ID, err)
+		}
+		r.Log.Info(fmt.Sprintf("Successfully adopted orphaned private nic %s on server %s", privateNIC.ID, server.ID))
+		return pnic, nil
+	}
+
+	for _, tag := range privateNIC.Tags {
+		if strings.HasPrefix(tag, constants.ClusterIDTagPrefix) {
+			return nil, fmt.Errorf("private nic %s on server %s belongs to cluster %s", privateNIC.ID, server.ID, strings.TrimPrefix(tag, constants.ClusterIDTagPrefix))
+		}
+	}
+
+	referenced := false
+	for _, nic := range nics {
+		if nic.Spec.ID == privateNIC.ID {
+			referenced = true
+			break
+		}
+	}
+
+	if !referenced && pn.Annotations[constants.AdoptPrivateNICsAnnotation] != "true" {
+		return nil, fmt.Errorf("private nic %s on server %s was not created by this controller, set the %s annotation to adopt it", privateNIC.ID, server.ID, constants.AdoptPrivateNICsAnnotation)
+	}
+
+	tags = append(tags, privateNIC.Tags...)
+	pnic, err := instanceAPI.UpdatePrivateNIC(&instance.UpdatePrivateNICRequest{
+		Zone:         server.Zone,
+		ServerID:     server.ID,
+		PrivateNicID: privateNIC.ID,
+		Tags:         &tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to adopt private nic %s on server %s: %w", privateNIC.ID, server.ID, err)
+	}
+	r.Log.Info(fmt.Sprintf("Successfully adopted private nic %s on server %s", privateNIC.ID, server.ID))
+	return pnic, nil
+}
+
+// replacePrivateNIC points the NetworkInterface to a new private NIC, when the previous one was removed out of band
+func (r *AttachmentReconciler) replacePrivateNIC(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, privateNIC *instance.PrivateNIC) error {
+	patch := client.MergeFrom(nic.DeepCopy())
+	nic.Spec.ID = privateNIC.ID
+	err := r.Client.Patch(ctx, nic, patch)
+	if err != nil {
+		return err
+	}
+
+	patch = client.MergeFrom(nic.DeepCopy())
+	nic.Status.MacAddress = privateNIC.MacAddress
+	nic.Status.LinkName = ""
+	nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhasePending
+	vpcv1alpha1.SetCondition(&nic.Status.Conditions, vpcv1alpha1.Condition{
+		Type:    vpcv1alpha1.NetworkInterfaceDegraded,
+		Status:  corev1.ConditionFalse,
+		Reason:  "PrivateNICRecreated",
+		Message: fmt.Sprintf("private nic replaced by %s", privateNIC.ID),
+	})
+	return r.Client.Status().Patch(ctx, nic, patch)
+}
+
+// setNetworkInterfaceDegraded marks the NetworkInterface as degraded
+func (r *AttachmentReconciler) setNetworkInterfaceDegraded(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, reason error) error {
+	patch := client.MergeFrom(nic.DeepCopy())
+	nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhaseDegraded
+	vpcv1alpha1.SetCondition(&nic.Status.Conditions, vpcv1alpha1.Condition{
+		Type:    vpcv1alpha1.NetworkInterfaceDegraded,
+		Status:  corev1.ConditionTrue,
+		Reason:  "PrivateNICUnavailable",
+		Message: reason.Error(),
+	})
+	return r.Client.Status().Patch(ctx, nic, patch)
+}
+
+// taintNode prevents pods from being scheduled on the node until the node daemon configured its NetworkInterfaces
+func (r *AttachmentReconciler) taintNode(ctx context.Context, node *corev1.Node) error {
+	patch := client.MergeFrom(node.DeepCopy())
+	if !taints.AddNotReady(node) {
+		return nil
+	}
+	err := r.Client.Patch(ctx, node, patch)
+	if err != nil {
+		return err
+	}
+	r.Log.Info(fmt.Sprintf("Successfully tainted node %s", node.Name))
+	return nil
+}
+
+// rejectDuplicateNetworkInterfaces keeps the oldest NetworkInterface and marks the other ones as degraded, so
+// that the node daemon ignores them. A server has a single private NIC per private network, so the duplicates
+// can't be backed by another private NIC; they are left for the user to delete.
+func (r *AttachmentReconciler) rejectDuplicateNetworkInterfaces(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface) ([]vpcv1alpha1.NetworkInterface, error) {
+	sort.Slice(nics, func(i, j int) bool {
+		if nics[i].CreationTimestamp.Equal(&nics[j].CreationTimestamp) {
+			return nics[i].Name < nics[j].Name
+		}
+		return nics[i].CreationTimestamp.Before(&nics[j].CreationTimestamp)
+	})
+
+	for i := range nics[1:] {
+		nic := &nics[i+1]
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() || isDuplicateNetworkInterface(nic) {
+			continue
+		}
+		patch := client.MergeFrom(nic.DeepCopy())
+		nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhaseDegraded
+		vpcv1alpha1.SetCondition(&nic.Status.Conditions, vpcv1alpha1.Condition{
+			Type:    vpcv1alpha1.NetworkInterfaceDegraded,
+			Status:  corev1.ConditionTrue,
+			Reason:  events.ReasonDuplicateNetworkInterface,
+			Message: fmt.Sprintf("networkInterface %s is already attaching node %s", nics[0].Name, nic.Spec.NodeName),
+		})
+		err := r.Client.Status().Patch(ctx, nic, patch)
+		if err != nil {
+			return nil, err
+		}
+		r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonDuplicateNetworkInterface, "Ignored, networkInterface %s is already attaching node %s, delete this one", nics[0].Name, nic.Spec.NodeName)
+		r.Recorder.Eventf(pn, corev1.EventTypeWarning, events.ReasonDuplicateNetworkInterface, "Ignored duplicate networkInterface %s of node %s", nic.Name, nic.Spec.NodeName)
+	}
+
+	return nics[:1], nil
+}
+
+// isDuplicateNetworkInterface returns whether the NetworkInterface was marked as a duplicate
+func isDuplicateNetworkInterface(nic *vpcv1alpha1.NetworkInterface) bool {
+	condition := vpcv1alpha1.FindCondition(nic.Status.Conditions, vpcv1alpha1.NetworkInterfaceDegraded)
+	return condition != nil && condition.Status == corev1.ConditionTrue && condition.Reason == events.ReasonDuplicateNetworkInterface
+}
+
+// setNetworkInterfaceNotDuplicate clears the duplicate mark of the NetworkInterface, so that the node daemon configures it
+func (r *AttachmentReconciler) setNetworkInterfaceNotDuplicate(ctx context.Context, nic *vpcv1alpha1.NetworkInterface) error {
+	patch := client.MergeFrom(nic.DeepCopy())
+	nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhasePending
+	vpcv1alpha1.SetCondition(&nic.Status.Conditions, vpcv1alpha1.Condition{
+		Type:    vpcv1alpha1.NetworkInterfaceDegraded,
+		Status:  corev1.ConditionFalse,
+		Reason:  "DuplicateRemoved",
+		Message: "the other networkInterfaces of the node were removed",
+	})
+	return r.Client.Status().Patch(ctx, nic, patch)
+}
+
+func (r *AttachmentReconciler) constructNetworkInterfaceForPrivateNetwork(pn *vpcv1alpha1.PrivateNetwork, nodeName string) (*vpcv1alpha1.NetworkInterface, error) {
+	nic := &vpcv1alpha1.NetworkInterface{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:       make(map[string]string),
+			Annotations:  make(map[string]string),
+			GenerateName: pn.Name + "-",
+		},
+		Spec: vpcv1alpha1.NetworkInterfaceSpec{
+			NodeName: nodeName,
+		},
+	}
+	for k, v := range pn.Annotations {
+		nic.Annotations[k] = v
+	}
+	for k, v := range pn.Labels {
+		nic.Labels[k] = v
+	}
+	nic.Labels[constants.PrivateNetworkLabel] = pn.Name
+	nic.Labels[constants.NodeLabel] = nodeName
+	if err := ctrl.SetControllerReference(pn, nic, r.Scheme); err != nil {
+		return nil, err
+	}
+	controllerutil.AddFinalizer(nic, constants.FinalizerName)
+	controllerutil.AddFinalizer(nic, constants.IPFinalizerName)
+
+	return nic, nil
+}
+
+// attachmentsForPrivateNetwork returns the requests for the attachments of the PrivateNetwork to every node,
+// and to the nodes it still has NetworkInterfaces on
+func (r *AttachmentReconciler) attachmentsForPrivateNetwork(a handler.MapObject) []reconcile.Request {
+	nodesList := &corev1.NodeList{}
+	err := r.Client.List(context.Background(), nodesList)
+	if err != nil {
+		r.Log.Error(err, "unable to list nodes")
+		return nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: a.Meta.GetName(),
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, "unable to list networkInterfaces")
+		return nil
+	}
+
+	nodeNames := make(map[string]struct{})
+	for _, node := range nodesList.Items {
+		nodeNames[node.Name] = struct{}{}
+	}
+	for _, nic := range nicsList.Items {
+		nodeNames[nic.Spec.NodeName] = struct{}{}
+	}
+
+	requests := []reconcile.Request{}
+	for nodeName := range nodeNames {
+		requests = append(requests, attachmentRequest(a.Meta.GetName(), nodeName))
+	}
+	return requests
+}
+
+// attachmentsForNodes returns the requests for the attachments of the PrivateNetworks selecting at least one of the nodes
+func (r *AttachmentReconciler) attachmentsForNodes(nodes ...*corev1.Node) []reconcile.Request {
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := r.Client.List(context.Background(), pnsList)
+	if err != nil {
+		r.Log.Error(err, "unable to list privateNetworks")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, pn := range pnsList.Items {
+		nodeSelector, err := pn.NodeLabelSelector()
+		if err != nil {
+			r.Log.Error(err, fmt.Sprintf("invalid nodeSelector on privateNetwork %s", pn.Name))
+			continue
+		}
+		for _, node := range nodes {
+			if nodeSelector.Matches(labels.Set(node.Labels)) {
+				requests = append(requests, attachmentRequest(pn.Name, node.Name))
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// attachmentsOnNode returns the requests for the attachments having a NetworkInterface on the node
+func (r *AttachmentReconciler) attachmentsOnNode(nodeName string) []reconcile.Request {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: nodeName,
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, "unable to list networkInterfaces")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, nic := range nicsList.Items {
+		requests = append(requests, attachmentRequest(nic.Labels[constants.PrivateNetworkLabel], nodeName))
+	}
+	return requests
+}
+
+func (r *AttachmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := controller.New("attachment", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{
+		Type: &vpcv1alpha1.PrivateNetwork{},
+	}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.attachmentsForPrivateNetwork),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{
+		Type: &vpcv1alpha1.NetworkInterface{},
+	}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			nic, ok := a.Object.(*vpcv1alpha1.NetworkInterface)
+			if !ok {
+				return nil
+			}
+			return []reconcile.Request{attachmentRequest(nic.Labels[constants.PrivateNetworkLabel], nic.Spec.NodeName)}
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{
+		Type: &corev1.Node{},
+	}, &handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			node, ok := e.Object.(*corev1.Node)
+			if !ok {
+				return
+			}
+			for _, req := range r.attachmentsForNodes(node) {
+				q.Add(req)
+			}
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return
+			}
+			if !nodeChanged(oldNode, newNode) {
+				return
+			}
+			// the old labels are needed to detach the PrivateNetworks not selecting the node anymore
+			for _, req := range r.attachmentsForNodes(oldNode, newNode) {
+				q.Add(req)
+			}
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			for _, req := range r.attachmentsOnNode(e.Meta.GetName()) {
+				q.Add(req)
+			}
+		},
+	})
+}
@@ -0,0 +1,208 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// ScalewayClients gives the Scaleway client of each PrivateNetwork: the default client,
+// or a client built from the Secret referenced by the PrivateNetwork
+type ScalewayClients struct {
+	// Client reads the credentials Secrets, it should not be cached so that
+	// the controller doesn't cache all the Secrets of the cluster
+	Client client.Reader
+	// Options are the options of all the clients, e.g. the user agent
+	Options []scw.ClientOption
+
+	lock sync.Mutex
+	// defaultClient is the client built from the credentials of the controller,
+	// nil until they are loaded and validated
+	defaultClient *scw.Client
+	// clients are the clients built from Secrets, rebuilt when the Secret changes
+	clients map[types.NamespacedName]*secretClient
+}
+
+type secretClient struct {
+	resourceVersion string
+	client          *scw.Client
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Default returns the client built from the credentials of the controller, or nil if they are not loaded
+func (s *ScalewayClients) Default() *scw.Client {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.defaultClient
+}
+
+// SetDefault replaces the client built from the credentials of the controller
+func (s *ScalewayClients) SetDefault(c *scw.Client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.defaultClient = c
+}
+
+// ForPrivateNetwork returns the Scaleway client of the PrivateNetwork
+func (s *ScalewayClients) ForPrivateNetwork(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork) (*scw.Client, error) {
+	ref := pn.Spec.CredentialsSecretRef
+	if ref == nil {
+		c := s.Default()
+		if c == nil {
+			return nil, fmt.Errorf("the scaleway credentials of the controller are not loaded")
+		}
+		return c, nil
+	}
+
+	key := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+	secret := &corev1.Secret{}
+	err := s.Client.Get(ctx, key, secret)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			s.lock.Lock()
+			delete(s.clients, key)
+			s.lock.Unlock()
+		}
+		return nil, fmt.Errorf("unable to get credentials secret %s: %w", key, err)
+	}
+
+	s.lock.Lock()
+	if c, ok := s.clients[key]; ok && c.resourceVersion == secret.ResourceVersion {
+		s.lock.Unlock()
+		return c.client, nil
+	}
+	c, err := s.newClientFromSecret(secret)
+	s.lock.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials secret %s: %w", key, err)
+	}
+
+	// the client is only cached once the credentials are accepted, like the default one
+	err = validateClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("credentials secret %s: %w", key, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[types.NamespacedName]*secretClient)
+	}
+	s.clients[key] = &secretClient{
+		resourceVersion: secret.ResourceVersion,
+		client:          c,
+	}
+	return c, nil
+}
+
+// newClientFromSecret builds a client from the credentials in the Secret, the default
+// zone and region of the default client are used when the Secret doesn't set them
+func (s *ScalewayClients) newClientFromSecret(secret *corev1.Secret) (*scw.Client, error) {
+	credentials := make(map[string]string, len(secret.Data))
+	for key, value := range secret.Data {
+		credentials[key] = string(value)
+	}
+
+	options := append([]scw.ClientOption{}, s.Options...)
+	if s.defaultClient != nil {
+		if zone, ok := s.defaultClient.GetDefaultZone(); ok {
+			options = append(options, scw.WithDefaultZone(zone))
+		}
+		if region, ok := s.defaultClient.GetDefaultRegion(); ok {
+			options = append(options, scw.WithDefaultRegion(region))
+		}
+	}
+
+	credentialsOptions, err := credentialsOptions(credentials)
+	if err != nil {
+		return nil, err
+	}
+	return scw.NewClient(append(options, credentialsOptions...)...)
+}
+
+// validateClient checks that the Scaleway API accepts the credentials of the client
+func validateClient(c *scw.Client) error {
+	_, err := vpc.NewAPI(c).ListPrivateNetworks(&vpc.ListPrivateNetworksRequest{
+		PageSize: scw.Uint32Ptr(1),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to validate scaleway credentials: %w", err)
+	}
+	return nil
+}
+
+// credentialsOptions returns the client options of the credentials, keyed by the name of their environment variable
+func credentialsOptions(credentials map[string]string) ([]scw.ClientOption, error) {
+	accessKey := credentials[scw.ScwAccessKeyEnv]
+	secretKey := credentials[scw.ScwSecretKeyEnv]
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("%s and %s are required", scw.ScwAccessKeyEnv, scw.ScwSecretKeyEnv)
+	}
+
+	options := []scw.ClientOption{
+		scw.WithAuth(accessKey, secretKey),
+	}
+	if projectID := credentials[scw.ScwDefaultProjectIDEnv]; projectID != "" {
+		options = append(options, scw.WithDefaultProjectID(projectID))
+	}
+	if organizationID := credentials[scw.ScwDefaultOrganizationIDEnv]; organizationID != "" {
+		options = append(options, scw.WithDefaultOrganizationID(organizationID))
+	}
+	if region := credentials[scw.ScwDefaultRegionEnv]; region != "" {
+		options = append(options, scw.WithDefaultRegion(scw.Region(region)))
+	}
+	if zone := credentials[scw.ScwDefaultZoneEnv]; zone != "" {
+		options = append(options, scw.WithDefaultZone(scw.Zone(zone)))
+	}
+	return options, nil
+}
+
+// isCredentialsSecretNotFound returns whether the credentials Secret of the PrivateNetwork doesn't exist
+func isCredentialsSecretNotFound(err error) bool {
+	var statusErr *apierrors.StatusError
+	return errors.As(err, &statusErr) && apierrors.IsNotFound(statusErr)
+}
+
+// isCredentialsError returns whether the Scaleway API refused the credentials
+func isCredentialsError(err error) bool {
+	var permissionsErr *scw.PermissionsDeniedError
+	if errors.As(err, &permissionsErr) {
+		return true
+	}
+	var responseErr *scw.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode == http.StatusUnauthorized || responseErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// credentialsKeys are the credentials read from the environment or the mounted Secret
+var credentialsKeys = []string{
+	scw.ScwAccessKeyEnv,
+	scw.ScwSecretKeyEnv,
+	scw.ScwDefaultProjectIDEnv,
+	scw.ScwDefaultOrganizationIDEnv,
+	scw.ScwDefaultRegionEnv,
+	scw.ScwDefaultZoneEnv,
+}
+
+// CredentialsLoader loads the Scaleway credentials of the controller and validates them with the API,
+// retrying with a backoff until they are valid. The credentials are read from the Secret mounted in Dir,
+// or from the environment, and reloaded when the Secret changes.
+type CredentialsLoader struct {
+	Log     logr.Logger
+	Clients *ScalewayClients
+	// Dir is the directory the credentials Secret is mounted in
+	Dir string
+	// Interval is the duration between two checks of the mounted Secret
+	Interval time.Duration
+
+	// checksum is the checksum of the last valid credentials
+	checksum string
+}
+
+// Start loads the credentials until the stop channel is closed
+func (l *CredentialsLoader) Start(stop <-chan struct{}) error {
+	backoff := l.backoff()
+	for {
+		delay := l.Interval
+		err := l.Load()
+		if err != nil {
+			delay = backoff.Step()
+			l.Log.Error(err, fmt.Sprintf("unable to load scaleway credentials, retrying in %s", delay))
+		} else {
+			backoff = l.backoff()
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// NeedLeaderElection loads the credentials on every replica, as they are used by the readiness check
+func (l *CredentialsLoader) NeedLeaderElection() bool {
+	return false
+}
+
+func (l *CredentialsLoader) backoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      5 * time.Minute,
+	}
+}
+
+// Load builds the default client if the credentials changed, and validates it before using it.
+// The previous client is kept until the new credentials are valid.
+func (l *CredentialsLoader) Load() error {
+	credentials, err := l.read()
+	if err != nil {
+		return err
+	}
+	checksum := credentialsChecksum(credentials)
+	if checksum == l.checksum && l.Clients.Default() != nil {
+		return nil
+	}
+
+	options, err := credentialsOptions(credentials)
+	if err != nil {
+		return err
+	}
+	c, err := scw.NewClient(append(append([]scw.ClientOption{}, l.Clients.Options...), options...)...)
+	if err != nil {
+		return err
+	}
+
+	err = validateClient(c)
+	if err != nil {
+		return err
+	}
+
+	l.Clients.SetDefault(c)
+	l.checksum = checksum
+	l.Log.Info("Successfully loaded scaleway credentials")
+	return nil
+}
+
+// read returns the credentials of the mounted Secret, or of the environment if it's not mounted
+func (l *CredentialsLoader) read() (map[string]string, error) {
+	credentials := make(map[string]string)
+
+	if l.Dir != "" {
+		_, err := os.Stat(filepath.Join(l.Dir, scw.ScwAccessKeyEnv))
+		if err == nil {
+			for _, key := range credentialsKeys {
+				value, err := ioutil.ReadFile(filepath.Join(l.Dir, key))
+				if err != nil {
+					if os.IsNotExist(err) {
+						continue
+					}
+					return nil, err
+				}
+				credentials[key] = strings.TrimSpace(string(value))
+			}
+			return credentials, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	for _, key := range credentialsKeys {
+		if value := os.Getenv(key); value != "" {
+			credentials[key] = value
+		}
+	}
+	return credentials, nil
+}
+
+func credentialsChecksum(credentials map[string]string) string {
+	keys := make([]string, 0, len(credentials))
+	for key := range credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%s\n", key, credentials[key])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
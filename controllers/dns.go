@@ -0,0 +1,300 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/dnsupdate"
+)
+
+const (
+	// hostsConfigMapKey is the key of the records in the hosts ConfigMap
+	hostsConfigMapKey = "hosts"
+	// tsigSecretKey is the key of the TSIG secret in the referenced Secret
+	tsigSecretKey = "secret"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// dnsRecords returns the addresses of the nodes attached to the PrivateNetwork, by record name
+func dnsRecords(pn *vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface) map[string][]net.IP {
+	records := make(map[string][]net.IP)
+	if pn.Spec.DNS == nil || !pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		return records
+	}
+
+	domain := strings.TrimSuffix(pn.Spec.DNS.Domain, ".")
+	for _, nic := range nics {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() || nic.Status.Address == "" {
+			continue
+		}
+		ip := net.ParseIP(strings.Split(nic.Status.Address, "/")[0])
+		if ip == nil {
+			continue
+		}
+		name := strings.ToLower(fmt.Sprintf("%s.%s.%s", nic.Spec.NodeName, pn.Name, domain))
+		records[name] = append(records[name], ip)
+	}
+	return records
+}
+
+// recordLines returns the records as sorted `<name> <address>` lines
+func recordLines(records map[string][]net.IP) []string {
+	lines := []string{}
+	for name, ips := range records {
+		for _, ip := range ips {
+			lines = append(lines, name+" "+ip.String())
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// syncDNS publishes the records of the nodes attached to the PrivateNetwork
+func (r *PrivateNetworkReconciler) syncDNS(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface) error {
+	records := dnsRecords(pn, nics)
+
+	err := r.syncHostsConfigMap(ctx, pn, records)
+	if err != nil {
+		return err
+	}
+	return r.syncRFC2136(ctx, pn, records)
+}
+
+// syncHostsConfigMap creates or updates the hosts ConfigMap of the PrivateNetwork,
+// and removes the ones that are not needed anymore
+func (r *PrivateNetworkReconciler) syncHostsConfigMap(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, records map[string][]net.IP) error {
+	var desired *types.NamespacedName
+	if pn.Spec.DNS != nil && pn.Spec.DNS.ConfigMap != nil && pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		desired = &types.NamespacedName{
+			Name:      pn.Spec.DNS.ConfigMap.Name,
+			Namespace: pn.Spec.DNS.ConfigMap.Namespace,
+		}
+		if desired.Name == "" {
+			desired.Name = pn.Name + "-hosts"
+		}
+		if desired.Namespace == "" {
+			desired.Namespace = "kube-system"
+		}
+
+		hosts := ""
+		for _, line := range recordLines(records) {
+			fields := strings.Fields(line)
+			hosts += fields[1] + " " + fields[0] + "\n"
+		}
+
+		cm := &corev1.ConfigMap{}
+		cm.Name = desired.Name
+		cm.Namespace = desired.Namespace
+		_, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+			if cm.Labels == nil {
+				cm.Labels = make(map[string]string)
+			}
+			cm.Labels[constants.PrivateNetworkLabel] = pn.Name
+			cm.Data = map[string]string{
+				hostsConfigMapKey: hosts,
+			}
+			return controllerutil.SetControllerReference(pn, cm, r.Scheme)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create or update hosts configMap: %w", err)
+		}
+	}
+
+	cmsList := &corev1.ConfigMapList{}
+	err := r.Client.List(ctx, cmsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pn.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list configMaps: %w", err)
+	}
+
+	for _, cm := range cmsList.Items {
+		if desired != nil && cm.Namespace == desired.Namespace && cm.Name == desired.Name {
+			continue
+		}
+		if _, ok := cm.Data[hostsConfigMapKey]; !ok {
+			continue
+		}
+		err := r.Client.Delete(ctx, &cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete configMap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncRFC2136 sends the records that changed since the last sync to the DNS server,
+// the records sent and their server are kept in the status of the PrivateNetwork
+func (r *PrivateNetworkReconciler) syncRFC2136(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, records map[string][]net.IP) error {
+	var desired *vpcv1alpha1.PrivateNetworkDNSRFC2136
+	if pn.Spec.DNS != nil {
+		desired = pn.Spec.DNS.RFC2136
+	}
+
+	// the records sent before the server was tracked were sent to the server of the spec
+	published := pn.Status.DNSServer
+	if published == nil {
+		published = desired
+	}
+	if published == nil {
+		// without a server, the previous records can't be removed
+		return r.patchDNSRecords(ctx, pn, nil, nil)
+	}
+
+	if !reflect.DeepEqual(published, desired) {
+		// the server was removed or changed, the records are removed from the previous server
+		err := r.deleteRFC2136Records(ctx, pn, published)
+		if err != nil {
+			return err
+		}
+		err = r.patchDNSRecords(ctx, pn, nil, nil)
+		if err != nil || desired == nil {
+			return err
+		}
+	}
+
+	c, err := r.dnsUpdateClient(ctx, pn, desired)
+	if err != nil {
+		return err
+	}
+
+	previous := previousDNSRecords(pn)
+
+	for name, ips := range records {
+		addresses := []string{}
+		for _, ip := range ips {
+			addresses = append(addresses, ip.String())
+		}
+		sort.Strings(addresses)
+		if reflect.DeepEqual(previous[name], addresses) {
+			continue
+		}
+		err := c.Replace(name, ips)
+		if err != nil {
+			return fmt.Errorf("unable to update record %s: %w", name, err)
+		}
+		r.Log.Info(fmt.Sprintf("Successfully updated record %s", name))
+	}
+
+	for name := range previous {
+		if _, ok := records[name]; ok {
+			continue
+		}
+		err := c.Delete(name)
+		if err != nil {
+			return fmt.Errorf("unable to delete record %s: %w", name, err)
+		}
+		r.Log.Info(fmt.Sprintf("Successfully deleted record %s", name))
+	}
+
+	return r.patchDNSRecords(ctx, pn, recordLines(records), desired)
+}
+
+// deleteRFC2136Records removes the records in the status of the PrivateNetwork from the DNS server
+func (r *PrivateNetworkReconciler) deleteRFC2136Records(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, rfc2136 *vpcv1alpha1.PrivateNetworkDNSRFC2136) error {
+	previous := previousDNSRecords(pn)
+	if len(previous) == 0 {
+		return nil
+	}
+
+	c, err := r.dnsUpdateClient(ctx, pn, rfc2136)
+	if err != nil {
+		return err
+	}
+	for name := range previous {
+		err := c.Delete(name)
+		if err != nil {
+			return fmt.Errorf("unable to delete record %s from %s: %w", name, rfc2136.Server, err)
+		}
+		r.Log.Info(fmt.Sprintf("Successfully deleted record %s from %s", name, rfc2136.Server))
+	}
+	return nil
+}
+
+// previousDNSRecords returns the addresses of the records in the status of the PrivateNetwork, by record name
+func previousDNSRecords(pn *vpcv1alpha1.PrivateNetwork) map[string][]string {
+	previous := make(map[string][]string)
+	for _, line := range pn.Status.DNSRecords {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			previous[fields[0]] = append(previous[fields[0]], fields[1])
+		}
+	}
+	return previous
+}
+
+// dnsUpdateClient returns the client sending the records of the PrivateNetwork to the DNS server
+func (r *PrivateNetworkReconciler) dnsUpdateClient(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, rfc2136 *vpcv1alpha1.PrivateNetworkDNSRFC2136) (*dnsupdate.Client, error) {
+	c := &dnsupdate.Client{
+		Server:  rfc2136.Server,
+		Zone:    rfc2136.Zone,
+		KeyName: rfc2136.TSIGKeyName,
+	}
+	if pn.Spec.DNS != nil {
+		c.TTL = uint32(pn.Spec.DNS.TTL)
+	}
+
+	if rfc2136.TSIGSecretRef != nil {
+		secret := &corev1.Secret{}
+		err := r.APIReader.Get(ctx, types.NamespacedName{
+			Name:      rfc2136.TSIGSecretRef.Name,
+			Namespace: rfc2136.TSIGSecretRef.Namespace,
+		}, secret)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get TSIG secret: %w", err)
+		}
+		c.Secret, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(secret.Data[tsigSecretKey])))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode TSIG secret: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (r *PrivateNetworkReconciler) patchDNSRecords(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, lines []string, server *vpcv1alpha1.PrivateNetworkDNSRFC2136) error {
+	if len(lines) == 0 {
+		lines = nil
+	}
+	if reflect.DeepEqual(lines, pn.Status.DNSRecords) && reflect.DeepEqual(server, pn.Status.DNSServer) {
+		return nil
+	}
+	patch := client.MergeFrom(pn.DeepCopy())
+	pn.Status.DNSRecords = lines
+	pn.Status.DNSServer = server.DeepCopy()
+	err := r.Status().Patch(ctx, pn, patch)
+	if err != nil {
+		return fmt.Errorf("unable to patch dns records: %w", err)
+	}
+	return nil
+}
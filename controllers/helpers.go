@@ -3,14 +3,25 @@ package controllers
 import (
 	"fmt"
 
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
 )
 
+// getServerFromNode returns the server of the node, in the zone of its providerID, or of its
+// topology label, or else in the default zone of the client
 func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.Server, error) {
 	instanceID := ""
 	zone := ""
+	if labelZone, err := scw.ParseZone(node.Labels[corev1.LabelZoneFailureDomainStable]); err == nil {
+		zone = string(labelZone)
+	}
 	if node.Spec.ProviderID != "" {
 		providerID := node.Spec.ProviderID
 		if providerIDRegexp.MatchString(providerID) {
@@ -53,3 +64,84 @@ func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.
 	}
 	return serversListResp.Servers[0], nil
 }
+
+// privateNICTags returns the Scaleway tags set on the private NICs created for the given PrivateNetwork
+func privateNICTags(clusterID string, pnName string) []string {
+	return []string{
+		constants.ClusterIDTagPrefix + clusterID,
+		constants.PrivateNetworkTagPrefix + pnName,
+	}
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTag returns the tags without the given tag
+func removeTag(tags []string, tag string) []string {
+	result := []string{}
+	for _, t := range tags {
+		if t != tag {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// hasReadyNetworkInterface returns whether one of the NetworkInterfaces is Ready
+func hasReadyNetworkInterface(nics []vpcv1alpha1.NetworkInterface) bool {
+	for _, nic := range nics {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() && nic.Status.Phase == vpcv1alpha1.NetworkInterfacePhaseReady {
+			return true
+		}
+	}
+	return false
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeChanged returns whether a node update may change its attachments
+func nodeChanged(oldNode, newNode *corev1.Node) bool {
+	return oldNode.Spec.ProviderID != newNode.Spec.ProviderID ||
+		!labels.Equals(oldNode.Labels, newNode.Labels) ||
+		isNodeReady(oldNode) != isNodeReady(newNode)
+}
+
+// staticCIDRs returns the prefixes the addresses are acquired from with the static IPAM
+func staticCIDRs(static *vpcv1alpha1.PrivateNetworkIPAMStatic) []string {
+	if len(static.AvailableRanges) != 0 {
+		return static.AvailableRanges
+	}
+	return []string{static.CIDR}
+}
+
+// acquireIP acquires an IP from the first prefix with a free address, and returns it with the prefix.
+// A nil IP is returned if no address is free.
+func acquireIP(log logr.Logger, ipam goipam.Ipamer, cidrs []string) (*goipam.IP, string) {
+	for _, cidr := range cidrs {
+		prefix, err := ipam.NewPrefix(cidr)
+		if err != nil {
+			log.Error(err, "error creating new prefix")
+			continue
+		}
+		ip, err := ipam.AcquireIP(prefix.Cidr)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
+			continue
+		}
+		return ip, prefix.Cidr
+	}
+	return nil, ""
+}
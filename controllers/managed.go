@@ -0,0 +1,143 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
+)
+
+// managedPrivateNetworkTags returns the tags of the Scaleway private network managed for the PrivateNetwork
+func managedPrivateNetworkTags(clusterID string, pn *vpcv1alpha1.PrivateNetwork) []string {
+	tags := privateNICTags(clusterID, pn.Name)
+	for _, tag := range pn.Spec.Managed.Tags {
+		if !hasTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ensureManagedPrivateNetwork returns the Scaleway private network managed for the PrivateNetwork, creating it
+// if needed, and keeps its name and tags in sync. A private network tagged for this PrivateNetwork by this cluster
+// is adopted instead of creating a new one, so that it is not leaked if the status could not be written.
+func (r *PrivateNetworkReconciler) ensureManagedPrivateNetwork(ctx context.Context, pnAPI privateNetworksAPI, pn *vpcv1alpha1.PrivateNetwork) (*scwPrivateNetwork, error) {
+	managed := pn.Spec.Managed
+	name := managed.Name
+	if name == "" {
+		name = pn.Name
+	}
+	tags := managedPrivateNetworkTags(r.ClusterID, pn)
+
+	var privateNetwork *scwPrivateNetwork
+	if pn.Status.ID != "" {
+		resp, err := pnAPI.Get(pn.Status.ID)
+		if err != nil && !isNotFoundError(err) {
+			return nil, err
+		}
+		if err == nil {
+			privateNetwork = resp
+		}
+	}
+
+	if privateNetwork == nil {
+		privateNetworks, err := pnAPI.ListByTags(privateNICTags(r.ClusterID, pn.Name))
+		if err != nil {
+			return nil, err
+		}
+		if len(privateNetworks) > 0 {
+			privateNetwork = privateNetworks[0]
+			r.Log.Info(fmt.Sprintf("Successfully adopted private network %s for %s", privateNetwork.ID, pn.Name))
+		}
+	}
+
+	if privateNetwork == nil {
+		created, err := pnAPI.Create(name, managed.ProjectID, tags)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create private network: %w", err)
+		}
+		privateNetwork = created
+		r.Log.Info(fmt.Sprintf("Successfully created private network %s for %s", privateNetwork.ID, pn.Name))
+		r.Recorder.Eventf(pn, corev1.EventTypeNormal, events.ReasonPrivateNetworkCreated, "Created private network %s", privateNetwork.ID)
+	}
+
+	if pn.Status.ID != privateNetwork.ID {
+		patch := client.MergeFrom(pn.DeepCopy())
+		pn.Status.ID = privateNetwork.ID
+		err := r.Status().Patch(ctx, pn, patch)
+		if err != nil {
+			return nil, fmt.Errorf("unable to patch status: %w", err)
+		}
+	}
+
+	currentTags := append([]string{}, privateNetwork.Tags...)
+	sort.Strings(currentTags)
+	if privateNetwork.Name != name || !reflect.DeepEqual(currentTags, tags) {
+		updated, err := pnAPI.Update(privateNetwork.ID, name, tags)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update private network %s: %w", privateNetwork.ID, err)
+		}
+		privateNetwork = updated
+		r.Log.Info(fmt.Sprintf("Successfully updated private network %s", privateNetwork.ID))
+	}
+
+	return privateNetwork, nil
+}
+
+// deleteManagedPrivateNetwork deletes the Scaleway private network managed for the PrivateNetwork,
+// unless its deletion policy retains it, or the private NICs attached to it are orphaned
+func (r *PrivateNetworkReconciler) deleteManagedPrivateNetwork(pnAPI privateNetworksAPI, pn *vpcv1alpha1.PrivateNetwork) error {
+	if pn.Spec.Managed == nil || pn.Status.ID == "" || pn.Spec.Managed.DeletionPolicy == vpcv1alpha1.ManagedDeletionPolicyRetain {
+		return nil
+	}
+	if pn.Spec.DeletionPolicy == vpcv1alpha1.DeletionPolicyOrphan {
+		// the orphaned private NICs still use the private network, which can't be deleted
+		r.Log.Info(fmt.Sprintf("Retaining private network %s of orphaned PrivateNetwork %s", pn.Status.ID, pn.Name))
+		r.Recorder.Eventf(pn, corev1.EventTypeWarning, events.ReasonPrivateNetworkRetained, "Retained private network %s despite the Delete managed deletion policy, since the private NICs are orphaned", pn.Status.ID)
+		return nil
+	}
+
+	err := pnAPI.Delete(pn.Status.ID)
+	if err != nil && !isNotFoundError(err) {
+		return err
+	}
+	r.Log.Info(fmt.Sprintf("Successfully deleted private network %s", pn.Status.ID))
+	r.Recorder.Eventf(pn, corev1.EventTypeNormal, events.ReasonPrivateNetworkDeleted, "Deleted private network %s", pn.Status.ID)
+	return nil
+}
+
+// isNotFoundError returns whether the Scaleway resource does not exist
+func isNotFoundError(err error) bool {
+	var notFoundErr *scw.ResourceNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return true
+	}
+	var responseErr *scw.ResponseError
+	return errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusNotFound
+}
@@ -30,6 +30,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -41,21 +42,24 @@ import (
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
 )
 
 // NetworkInterfaceReconciler reconciles a NetworkInterface object
 type NetworkInterfaceReconciler struct {
 	client.Client
-	Log         logr.Logger
-	Scheme      *runtime.Scheme
-	IPAM        goipam.Ipamer
-	InstanceAPI *instance.API
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	IPAM     goipam.Ipamer
+	Scaleway *ScalewayClients
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -102,32 +106,14 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				if pn.Spec.IPAM.Static == nil {
 					return ctrl.Result{}, fmt.Errorf("Static CIDR can't be empty on static ipam mode")
 				}
-				cidrs := []string{pn.Spec.IPAM.Static.CIDR}
-				if len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
-					cidrs = pn.Spec.IPAM.Static.AvailableRanges
-				}
-
-				var ip *goipam.IP
-				var chosenCidr string
-
-				for _, cidr := range cidrs {
-					prefix, err := r.IPAM.NewPrefix(cidr)
-					if err != nil {
-						log.Error(err, "error creating new prefix")
-						continue
-					}
-					ip, err = r.IPAM.AcquireIP(prefix.Cidr)
-					if err != nil {
-						log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
-						continue
-					}
-					chosenCidr = prefix.Cidr
-					break
-				}
+				cidrs := staticCIDRs(pn.Spec.IPAM.Static)
+				ip, chosenCidr := acquireIP(log, r.IPAM, cidrs)
 
 				if ip == nil {
 					err := fmt.Errorf("could not acquire IP")
 					log.Error(err, "error while testing all cidrs")
+					r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonIPAllocationFailed, "Could not acquire IP in %s", strings.Join(cidrs, ", "))
+					r.Recorder.Eventf(&pn, corev1.EventTypeWarning, events.ReasonIPAllocationFailed, "Could not acquire IP for node %s", nic.Spec.NodeName)
 					return ctrl.Result{RequeueAfter: RequeueDuration}, err
 				}
 
@@ -144,10 +130,37 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 					log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
 					return ctrl.Result{}, err
 				}
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonIPAllocated, "Allocated IP %s from %s", nic.Status.Address, chosenCidr)
 			default:
 				return ctrl.Result{}, fmt.Errorf("IPAM type %s is not supported", pn.Spec.IPAM.Type)
 			}
 		}
+		parents, length, ok, err := podCIDRParents(&pn)
+		if err != nil {
+			log.Error(err, "invalid pod cidr")
+			return ctrl.Result{}, err
+		}
+		if ok && nic.Status.MacAddress != "" && nic.Status.PodCIDR == "" {
+			prefix, err := acquirePodCIDR(r.IPAM, parents, length)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("error acquiring pod cidr from %s", strings.Join(parents, ", ")))
+				r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonIPAllocationFailed, "Could not acquire a /%d pod CIDR in %s", length, strings.Join(parents, ", "))
+				return ctrl.Result{RequeueAfter: RequeueDuration}, err
+			}
+
+			patch := client.MergeFrom(nic.DeepCopy())
+			nic.Status.PodCIDR = prefix.Cidr
+			err = r.Client.Status().Patch(ctx, nic, patch)
+			if err != nil {
+				ipamErr := r.IPAM.ReleaseChildPrefix(prefix)
+				if ipamErr != nil {
+					log.Error(ipamErr, fmt.Sprintf("failed to release pod cidr %s", prefix.Cidr))
+				}
+				log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonPodCIDRAllocated, "Allocated pod CIDR %s from %s", prefix.Cidr, prefix.ParentCidr)
+		}
 		// nothing left to do
 		return ctrl.Result{}, nil
 	}
@@ -181,6 +194,19 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 					log.Error(err, fmt.Sprintf("could not delete IP %s from prefix %s", nic.Status.Address, cidr))
 					return ctrl.Result{}, err
 				}
+			} else {
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonIPReleased, "Released IP %s from %s", nic.Status.Address, cidr)
+			}
+		}
+		if nic.Status.PodCIDR != "" {
+			prefix := r.IPAM.PrefixFrom(nic.Status.PodCIDR)
+			if prefix != nil {
+				err := r.IPAM.ReleaseChildPrefix(prefix)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("could not release pod cidr %s", nic.Status.PodCIDR))
+					return ctrl.Result{}, err
+				}
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonPodCIDRReleased, "Released pod CIDR %s", nic.Status.PodCIDR)
 			}
 		}
 		node := corev1.Node{}
@@ -190,28 +216,55 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			return ctrl.Result{}, err
 		}
 		if err == nil {
-			server, err := getServerFromNode(r.InstanceAPI, &node)
+			scwClient, err := r.Scaleway.ForPrivateNetwork(ctx, &pn)
+			if err != nil {
+				log.Error(err, "unable to get scaleway client")
+				return ctrl.Result{}, err
+			}
+			instanceAPI := instance.NewAPI(scwClient)
+			server, err := getServerFromNode(instanceAPI, &node)
 			if err != nil {
 				log.Error(err, "error getting server from node")
 				return ctrl.Result{}, err
 			}
-			privateNicID := ""
+			shared, err := r.isPrivateNICShared(ctx, nic)
+			if err != nil {
+				log.Error(err, "unable to list networkInterfaces")
+				return ctrl.Result{}, err
+			}
+			var privateNIC *instance.PrivateNIC
 			for _, pnic := range server.PrivateNics {
-				if pnic.PrivateNetworkID == pn.Spec.ID {
-					privateNicID = pnic.ID
+				if pnic.ID == nic.Spec.ID {
+					privateNIC = pnic
 					break
 				}
 			}
-			if privateNicID != "" {
-				err := r.InstanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
+			if privateNIC != nil && !shared && nic.Annotations[constants.OrphanAnnotation] == "true" {
+				if !hasTag(privateNIC.Tags, constants.OrphanedTag) {
+					tags := append(append([]string{}, privateNIC.Tags...), constants.OrphanedTag)
+					_, err := instanceAPI.UpdatePrivateNIC(&instance.UpdatePrivateNICRequest{
+						Zone:         server.Zone,
+						ServerID:     server.ID,
+						PrivateNicID: privateNIC.ID,
+						Tags:         &tags,
+					})
+					if err != nil {
+						log.Error(err, "unable to tag orphaned private nic")
+						return ctrl.Result{}, err
+					}
+				}
+				r.Recorder.Eventf(events.NodeRef(node.Name), corev1.EventTypeNormal, events.ReasonPrivateNICOrphaned, "Left private nic %s of private network %s attached", privateNIC.ID, pn.Name)
+			} else if privateNIC != nil && !shared {
+				err := instanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
 					Zone:         server.Zone,
-					PrivateNicID: privateNicID,
+					PrivateNicID: privateNIC.ID,
 					ServerID:     server.ID,
 				})
 				if err != nil {
 					log.Error(err, "unable to delete private nic from server")
 					return ctrl.Result{}, err
 				}
+				r.Recorder.Eventf(events.NodeRef(node.Name), corev1.EventTypeNormal, events.ReasonDetached, "Detached private network %s", pn.Name)
 			}
 		}
 
@@ -227,6 +280,24 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	return ctrl.Result{}, nil
 }
 
+// isPrivateNICShared returns whether another NetworkInterface uses the same private NIC
+func (r *NetworkInterfaceReconciler) isPrivateNICShared(ctx context.Context, nic *vpcv1alpha1.NetworkInterface) (bool, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: nic.Spec.NodeName,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, n := range nicsList.Items {
+		if n.Name != nic.Name && n.Spec.ID == nic.Spec.ID && n.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&vpcv1alpha1.NetworkInterface{}).
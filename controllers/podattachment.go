@@ -0,0 +1,159 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// networkAttachmentDefinitionGVK is the kind of the Multus NetworkAttachmentDefinitions
+var networkAttachmentDefinitionGVK = schema.GroupVersionKind{
+	Group:   "k8s.cni.cncf.io",
+	Version: "v1",
+	Kind:    "NetworkAttachmentDefinition",
+}
+
+// cniConfig is the CNI configuration of the pods attached to a PrivateNetwork
+type cniConfig struct {
+	CNIVersion string        `json:"cniVersion"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Master     string        `json:"master"`
+	Mode       string        `json:"mode"`
+	IPAM       cniIPAMConfig `json:"ipam"`
+}
+
+// cniIPAMConfig is the configuration of the IPAM plugin of this project
+type cniIPAMConfig struct {
+	Type           string `json:"type"`
+	PrivateNetwork string `json:"privateNetwork"`
+}
+
+// +kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=network-attachment-definitions,verbs=get;list;watch;create;update;patch;delete
+
+// networkAttachmentDefinition returns the NetworkAttachmentDefinition attaching the pods to the PrivateNetwork
+func networkAttachmentDefinition(pn *vpcv1alpha1.PrivateNetwork) (*unstructured.Unstructured, error) {
+	pa := pn.Spec.PodAttachment
+
+	master, err := pn.RenderInterfaceName()
+	if err != nil {
+		return nil, err
+	}
+
+	config := cniConfig{
+		CNIVersion: "0.3.1",
+		Name:       pn.Name,
+		Type:       string(pa.Mode),
+		Master:     master,
+		IPAM: cniIPAMConfig{
+			Type:           constants.IPAMPluginName,
+			PrivateNetwork: pn.Name,
+		},
+	}
+	switch pa.Mode {
+	case vpcv1alpha1.PodAttachmentModeIPvlan:
+		config.Mode = "l2"
+	default:
+		config.Type = string(vpcv1alpha1.PodAttachmentModeMacvlan)
+		config.Mode = "bridge"
+	}
+
+	rawConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := pa.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	nad := &unstructured.Unstructured{}
+	nad.SetGroupVersionKind(networkAttachmentDefinitionGVK)
+	nad.SetName(pn.Name)
+	nad.SetNamespace(namespace)
+	nad.SetLabels(map[string]string{
+		constants.PrivateNetworkLabel: pn.Name,
+	})
+	err = unstructured.SetNestedField(nad.Object, string(rawConfig), "spec", "config")
+	if err != nil {
+		return nil, err
+	}
+	return nad, nil
+}
+
+// syncNetworkAttachmentDefinition creates or updates the NetworkAttachmentDefinition of the PrivateNetwork,
+// and removes the ones that are not needed anymore
+func (r *PrivateNetworkReconciler) syncNetworkAttachmentDefinition(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork) error {
+	var desired *unstructured.Unstructured
+	if pn.Spec.PodAttachment != nil && pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		nad, err := networkAttachmentDefinition(pn)
+		if err != nil {
+			return err
+		}
+		desired = nad
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(networkAttachmentDefinitionGVK)
+		existing.SetName(desired.GetName())
+		existing.SetNamespace(desired.GetNamespace())
+		_, err = controllerutil.CreateOrUpdate(ctx, r.Client, existing, func() error {
+			existing.SetLabels(desired.GetLabels())
+			existing.Object["spec"] = desired.Object["spec"]
+			return controllerutil.SetControllerReference(pn, existing, r.Scheme)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create or update networkAttachmentDefinition: %w", err)
+		}
+	}
+
+	nadsList := &unstructured.UnstructuredList{}
+	nadsList.SetGroupVersionKind(networkAttachmentDefinitionGVK.GroupVersion().WithKind(networkAttachmentDefinitionGVK.Kind + "List"))
+	err := r.Client.List(ctx, nadsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pn.Name,
+	})
+	if err != nil {
+		if desired == nil && meta.IsNoMatchError(err) {
+			// Multus is not installed
+			return nil
+		}
+		return fmt.Errorf("unable to list networkAttachmentDefinitions: %w", err)
+	}
+
+	for _, nad := range nadsList.Items {
+		if desired != nil && nad.GetNamespace() == desired.GetNamespace() && nad.GetName() == desired.GetName() {
+			continue
+		}
+		err := r.Client.Delete(ctx, &nad)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete networkAttachmentDefinition %s/%s: %w", nad.GetNamespace(), nad.GetName(), err)
+		}
+	}
+	return nil
+}
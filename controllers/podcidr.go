@@ -0,0 +1,116 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+
+	goipam "github.com/metal-stack/go-ipam"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// defaultNodePrefixLength is the default length of the prefix carved out of the pod attachment CIDR for each node
+const defaultNodePrefixLength = 26
+
+// podCIDRParents returns the prefixes the pod CIDRs of the nodes are carved out of, and their length.
+// With podCIDRSize, the static CIDR is split in the largest prefixes that don't overlap the available ranges
+// of the nodes, so that go-ipam only returns pod CIDRs outside of them.
+func podCIDRParents(pn *vpcv1alpha1.PrivateNetwork) ([]string, uint8, bool, error) {
+	if pn.Spec.PodAttachment != nil {
+		length := pn.Spec.PodAttachment.NodePrefixLength
+		if length == 0 {
+			length = defaultNodePrefixLength
+		}
+		return []string{pn.Spec.PodAttachment.CIDR}, uint8(length), true, nil
+	}
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Static == nil || pn.Spec.IPAM.Static.PodCIDRSize == 0 {
+		return nil, 0, false, nil
+	}
+	static := pn.Spec.IPAM.Static
+	length := uint8(static.PodCIDRSize)
+
+	_, cidr, err := net.ParseCIDR(static.CIDR)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("invalid static CIDR %s: %w", static.CIDR, err)
+	}
+	excluded := []*net.IPNet{}
+	for _, r := range static.AvailableRanges {
+		_, availableRange, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("invalid available range %s: %w", r, err)
+		}
+		excluded = append(excluded, availableRange)
+	}
+
+	parents := []string{}
+	for _, block := range subtractCIDRs(cidr, excluded) {
+		// go-ipam can only carve prefixes strictly longer than their parent
+		if ones, _ := block.Mask.Size(); ones < int(length) {
+			parents = append(parents, block.String())
+		}
+	}
+	return parents, length, true, nil
+}
+
+// subtractCIDRs returns the largest prefixes of cidr that don't overlap the excluded ones
+func subtractCIDRs(cidr *net.IPNet, excluded []*net.IPNet) []*net.IPNet {
+	overlapping := false
+	for _, e := range excluded {
+		if e.Contains(cidr.IP) && bitsOf(e) <= bitsOf(cidr) {
+			// the whole prefix is excluded
+			return nil
+		}
+		if cidr.Contains(e.IP) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return []*net.IPNet{cidr}
+	}
+
+	ones, bits := cidr.Mask.Size()
+	mask := net.CIDRMask(ones+1, bits)
+	low := &net.IPNet{IP: cidr.IP.Mask(mask), Mask: mask}
+	high := &net.IPNet{IP: make(net.IP, len(low.IP)), Mask: mask}
+	copy(high.IP, low.IP)
+	high.IP[ones/8] |= 0x80 >> uint(ones%8)
+	return append(subtractCIDRs(low, excluded), subtractCIDRs(high, excluded)...)
+}
+
+func bitsOf(cidr *net.IPNet) int {
+	ones, _ := cidr.Mask.Size()
+	return ones
+}
+
+// acquirePodCIDR acquires a child prefix of the first parent with room left
+func acquirePodCIDR(ipam goipam.Ipamer, parents []string, length uint8) (*goipam.Prefix, error) {
+	err := fmt.Errorf("no prefix to carve a /%d pod CIDR out of", length)
+	for _, parent := range parents {
+		_, err = ipam.NewPrefix(parent)
+		if err != nil {
+			return nil, fmt.Errorf("error creating prefix %s: %w", parent, err)
+		}
+		var prefix *goipam.Prefix
+		prefix, err = ipam.AcquireChildPrefix(parent, length)
+		if err == nil {
+			return prefix, nil
+		}
+	}
+	return nil, err
+}
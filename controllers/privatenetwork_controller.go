@@ -21,29 +21,20 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
-	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	goipam "github.com/metal-stack/go-ipam"
-	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
-	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
-	"github.com/scaleway/scaleway-sdk-go/scw"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/util/workqueue"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
 )
 
 const (
@@ -57,25 +48,33 @@ var (
 
 	// RequeueDuration is the default requeue duration
 	RequeueDuration time.Duration = time.Second * 30
+
+	// DriftCheckDuration is the duration between two checks of the private NICs on the servers
+	DriftCheckDuration time.Duration = time.Minute * 5
 )
 
 // PrivateNetworkReconciler reconciles a PrivateNetwork object
 type PrivateNetworkReconciler struct {
 	client.Client
-	Log         logr.Logger
-	Scheme      *runtime.Scheme
-	IPAM        goipam.Ipamer
-	InstanceAPI *instance.API
-	VpcAPI      *vpc.API
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	IPAM      goipam.Ipamer
+	Scaleway  *ScalewayClients
+	ClusterID string
+	// APIReader reads the TSIG Secrets without caching all the Secrets of the cluster
+	APIReader client.Reader
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update
-// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
+// Reconcile handles the lifecycle of the PrivateNetwork, the attachment to the nodes
+// is handled by the AttachmentReconciler
 func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("privatenetwork", req.NamespacedName)
@@ -89,8 +88,12 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 	}
 
 	if pn.Spec.CIDR != "" {
-		res, err := r.ReconcileDeprecated(req)
-		return res, err
+		// deprecated
+		_, err := r.IPAM.NewPrefix(pn.Spec.CIDR)
+		if err != nil {
+			log.Error(err, "error creating new prefix")
+			return ctrl.Result{}, err
+		}
 	}
 
 	if !pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
@@ -109,6 +112,18 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 
 			for _, nic := range nicsList.Items {
 				if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+					if pn.Spec.DeletionPolicy == vpcv1alpha1.DeletionPolicyOrphan && nic.Annotations[constants.OrphanAnnotation] != "true" {
+						patch := client.MergeFrom(nic.DeepCopy())
+						if nic.Annotations == nil {
+							nic.Annotations = make(map[string]string)
+						}
+						nic.Annotations[constants.OrphanAnnotation] = "true"
+						err := r.Client.Patch(ctx, &nic, patch)
+						if err != nil {
+							log.Error(err, fmt.Sprintf("failed to orphan networkInterface %s", nic.Name))
+							return ctrl.Result{}, err
+						}
+					}
 					err := r.Client.Delete(ctx, &nic)
 					if err != nil {
 						log.Error(err, fmt.Sprintf("failed to delete networkInterface %s", nic.Name))
@@ -117,11 +132,56 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 				}
 			}
 			if len(nicsList.Items) == 0 {
-				_, err = r.IPAM.DeletePrefix(pn.Spec.CIDR)
+				err := r.syncDNS(ctx, pn, nil)
 				if err != nil {
-					if !errors.As(err, &goipam.NotFoundError{}) {
-						log.Error(err, "failed to delete PrivateNetwork prefix")
-						return ctrl.Result{}, err
+					log.Error(err, "failed to delete dns records")
+					return ctrl.Result{}, err
+				}
+				err = r.syncNetworkAttachmentDefinition(ctx, pn)
+				if err != nil {
+					log.Error(err, "failed to delete networkAttachmentDefinition")
+					return ctrl.Result{}, err
+				}
+				parents, _, _, err := podCIDRParents(pn)
+				if err != nil {
+					log.Error(err, "invalid pod cidr")
+				}
+				for _, parent := range parents {
+					_, err = r.IPAM.DeletePrefix(parent)
+					if err != nil {
+						if !errors.As(err, &goipam.NotFoundError{}) {
+							log.Error(err, "failed to delete pod cidr prefix")
+							return ctrl.Result{}, err
+						}
+					}
+				}
+				if pn.Spec.Managed != nil {
+					scwClient, err := r.Scaleway.ForPrivateNetwork(ctx, pn)
+					if err != nil && !isCredentialsSecretNotFound(err) && !isCredentialsError(err) {
+						log.Error(err, "unable to get scaleway client")
+						r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidCredentials, err.Error())
+						return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+					}
+					if err != nil {
+						// the credentials Secret may be deleted before the PrivateNetwork, e.g. with the same
+						// manifest, the private network is then retained instead of blocking the deletion
+						log.Error(err, fmt.Sprintf("unable to delete private network %s, retaining it", pn.Status.ID))
+						r.Recorder.Eventf(pn, corev1.EventTypeWarning, events.ReasonPrivateNetworkRetained, "Retained private network %s, it must be deleted manually: %s", pn.Status.ID, err)
+					} else {
+						err = r.deleteManagedPrivateNetwork(newPrivateNetworksAPI(scwClient, pn), pn)
+						if err != nil {
+							log.Error(err, fmt.Sprintf("failed to delete private network %s", pn.Status.ID))
+							return ctrl.Result{}, err
+						}
+					}
+				}
+				if pn.Spec.CIDR != "" {
+					_, err = r.IPAM.DeletePrefix(pn.Spec.CIDR)
+					if err != nil {
+						if !errors.As(err, &goipam.NotFoundError{}) {
+							log.Error(err, "failed to delete PrivateNetwork prefix")
+							return ctrl.Result{}, err
+						}
 					}
 				}
 				patch := client.MergeFrom(pn.DeepCopy())
@@ -146,308 +206,165 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		}
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
-		Zone:             scw.Zone(pn.Spec.Zone),
-		PrivateNetworkID: pn.Spec.ID,
-	})
+	err = pn.ValidateManaged()
 	if err != nil {
-		log.Error(err, "error getting private network from api")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "invalid id")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	nodesList := &corev1.NodeList{}
-	err = r.Client.List(ctx, nodesList)
+	err = pn.ValidateLocality()
 	if err != nil {
-		log.Error(err, "could not list nodes")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "invalid locality")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	for _, node := range nodesList.Items {
-		nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-		err = r.Client.List(ctx, nicsList,
-			client.MatchingLabels{
-				constants.PrivateNetworkLabel: pn.Name,
-				constants.NodeLabel:           node.Name,
-			},
-		)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
-		}
-
-		server, err := getServerFromNode(r.InstanceAPI, &node)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
-			break
-		}
-
-		var privateNIC *instance.PrivateNIC
-		for _, pnic := range server.PrivateNics {
-			if pnic.PrivateNetworkID == pn.Spec.ID {
-				privateNIC = pnic
-				break
-			}
-		}
-		if privateNIC == nil {
-			pnicResp, err := r.InstanceAPI.CreatePrivateNIC(&instance.CreatePrivateNICRequest{
-				Zone:             server.Zone,
-				PrivateNetworkID: pn.Spec.ID,
-				ServerID:         server.ID,
-			})
-			if err != nil {
-				log.Error(err, fmt.Sprintf("unable to create private on server %s", server.ID))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			privateNIC = pnicResp.PrivateNic
-		}
-
-		if len(nicsList.Items) > 1 {
-			log.Error(fmt.Errorf("node %s have %d networkInterfaces instead of at most one", node.Name, len(nicsList.Items)), "could not handle node")
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
-		}
-
-		if len(nicsList.Items) == 0 {
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
-			if err != nil {
-				log.Error(err, "unable to construct networkInterface from privateNetwork")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
+	err = pn.ValidateCredentialsSecretRef()
+	if err != nil {
+		log.Error(err, "invalid credentials secret reference")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, r.setCredentialsValid(ctx, pn, corev1.ConditionFalse, "CredentialsUnavailable", err.Error())
+	}
 
-			nic.Spec.ID = privateNIC.ID
-			err = r.Client.Create(ctx, nic)
-			if err != nil {
-				log.Error(err, "could not create networkInterface")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			patch := client.MergeFrom(nic.DeepCopy())
-			nic.Status.MacAddress = privateNIC.MacAddress
-			err = r.Client.Status().Patch(ctx, nic, patch)
-			if err != nil {
-				log.Error(err, "could not patch networkInterface status")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
+	scwClient, err := r.Scaleway.ForPrivateNetwork(ctx, pn)
+	if err != nil {
+		log.Error(err, "unable to get scaleway client")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidCredentials, err.Error())
+		reason := "CredentialsUnavailable"
+		if isCredentialsError(err) {
+			reason = "CredentialsRefused"
 		}
+		return ctrl.Result{RequeueAfter: RequeueDuration}, r.setCredentialsValid(ctx, pn, corev1.ConditionFalse, reason, err.Error())
 	}
 
-	return ctrl.Result{}, nil
-}
-
-func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
-	log := r.Log.WithValues("privatenetwork", req.NamespacedName)
-
-	pn := &vpcv1alpha1.PrivateNetwork{}
-
-	err := r.Get(ctx, req.NamespacedName, pn)
+	pnAPI := newPrivateNetworksAPI(scwClient, pn)
+	if pn.Spec.Managed != nil {
+		_, err = r.ensureManagedPrivateNetwork(ctx, pnAPI, pn)
+	} else {
+		_, err = pnAPI.Get(pn.Spec.ID)
+	}
+	if err != nil && isCredentialsError(err) {
+		log.Error(err, "scaleway credentials refused")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidCredentials, err.Error())
+		return ctrl.Result{RequeueAfter: RequeueDuration}, r.setCredentialsValid(ctx, pn, corev1.ConditionFalse, "CredentialsRefused", err.Error())
+	}
+	condErr := r.setCredentialsValid(ctx, pn, corev1.ConditionTrue, "CredentialsAccepted", "")
+	if condErr != nil {
+		log.Error(condErr, "unable to patch status")
+		return ctrl.Result{}, condErr
+	}
 	if err != nil {
-		log.Error(err, "could not find object")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		log.Error(err, "error getting private network from api")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonPrivateNetworkNotFound, err.Error())
+		return ctrl.Result{RequeueAfter: RequeueDuration}, err
 	}
 
-	prefix, err := r.IPAM.NewPrefix(pn.Spec.CIDR)
+	_, err = pn.RenderInterfaceName()
 	if err != nil {
-		log.Error(err, "error creating new prefix")
+		log.Error(err, "invalid interfaceName")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
 		return ctrl.Result{}, err
 	}
 
-	if !pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
-		// deletion
-		if controllerutil.ContainsFinalizer(pn, constants.FinalizerName) {
-			nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-			err = r.Client.List(ctx, nicsList,
-				client.MatchingLabels{
-					constants.PrivateNetworkLabel: pn.Name,
-				},
-			)
-			if err != nil {
-				log.Error(err, fmt.Sprintf("could not list NetworkInterface for privateNetwork %s", pn.Name))
-				return ctrl.Result{}, err
-			}
-
-			for _, nic := range nicsList.Items {
-				if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
-					err := r.Client.Delete(ctx, &nic)
-					if err != nil {
-						log.Error(err, fmt.Sprintf("failed to delete networkInterface %s", nic.Name))
-						return ctrl.Result{}, err
-					}
-				}
-			}
-			if len(nicsList.Items) == 0 {
-				_, err = r.IPAM.DeletePrefix(pn.Spec.CIDR)
-				if err != nil {
-					if !errors.As(err, &goipam.NotFoundError{}) {
-						log.Error(err, "failed to delete PrivateNetwork prefix")
-						return ctrl.Result{}, err
-					}
-				}
-				patch := client.MergeFrom(pn.DeepCopy())
-				controllerutil.RemoveFinalizer(pn, constants.FinalizerName)
-				if err := r.Patch(ctx, pn, patch); err != nil {
-					log.Error(err, "failed to add finalizer")
-					return ctrl.Result{}, err
-				}
-				return ctrl.Result{}, nil
-			}
-			return ctrl.Result{RequeueAfter: RequeueDuration}, nil
-		}
-		return ctrl.Result{}, nil
+	_, err = pn.NodeLabelSelector()
+	if err != nil {
+		log.Error(err, "invalid nodeSelector")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	if !controllerutil.ContainsFinalizer(pn, constants.FinalizerName) {
-		patch := client.MergeFrom(pn.DeepCopy())
-		controllerutil.AddFinalizer(pn, constants.FinalizerName)
-		if err := r.Patch(ctx, pn, patch); err != nil {
-			log.Error(err, "failed to add finalizer")
-			return ctrl.Result{}, err
-		}
+	err = pn.ValidateDNS()
+	if err != nil {
+		log.Error(err, "invalid dns")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
-		Zone:             scw.Zone(pn.Spec.Zone),
-		PrivateNetworkID: pn.Spec.ID,
-	})
+	err = pn.ValidateIngressRules()
 	if err != nil {
-		log.Error(err, "error getting private network from api")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "invalid ingressRules")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	nodesList := &corev1.NodeList{}
-	err = r.Client.List(ctx, nodesList)
+	err = pn.ValidatePodAttachment()
 	if err != nil {
-		log.Error(err, "could not list nodes")
-		return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		log.Error(err, "invalid podAttachment")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
 	}
 
-	for _, node := range nodesList.Items {
-		nicsList := &vpcv1alpha1.NetworkInterfaceList{}
-		err = r.Client.List(ctx, nicsList,
-			client.MatchingLabels{
-				constants.PrivateNetworkLabel: pn.Name,
-				constants.NodeLabel:           node.Name,
-			},
-		)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("could not list NetworkInterface for node %s and privateNetwork %s", node.Name, pn.Name))
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
-		}
+	err = pn.ValidatePodCIDRSize()
+	if err != nil {
+		log.Error(err, "invalid podCIDRSize")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
+	}
 
-		server, err := getServerFromNode(r.InstanceAPI, &node)
+	// the pod CIDRs of the nodes are carved out of their own prefixes
+	parents, _, _, err := podCIDRParents(pn)
+	if err != nil {
+		log.Error(err, "invalid pod cidr")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
+	}
+	for _, parent := range parents {
+		_, err := r.IPAM.NewPrefix(parent)
 		if err != nil {
-			log.Error(err, fmt.Sprintf("could not get scaleway server from node %s", node.Name))
-			break
-		}
-
-		var privateNIC *instance.PrivateNIC
-		for _, pnic := range server.PrivateNics {
-			if pnic.PrivateNetworkID == pn.Spec.ID {
-				privateNIC = pnic
-				break
-			}
-		}
-		if privateNIC == nil {
-			pnicResp, err := r.InstanceAPI.CreatePrivateNIC(&instance.CreatePrivateNICRequest{
-				Zone:             server.Zone,
-				PrivateNetworkID: pn.Spec.ID,
-				ServerID:         server.ID,
-			})
-			if err != nil {
-				log.Error(err, fmt.Sprintf("unable to create private on server %s", server.ID))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			privateNIC = pnicResp.PrivateNic
+			log.Error(err, "error creating pod cidr prefix")
+			return ctrl.Result{}, err
 		}
+	}
 
-		if len(nicsList.Items) > 1 {
-			log.Error(fmt.Errorf("node %s have %d networkInterfaces instead of at most one", node.Name, len(nicsList.Items)), "could not handle node")
-			return ctrl.Result{RequeueAfter: RequeueDuration}, err
-		}
+	err = r.syncNetworkAttachmentDefinition(ctx, pn)
+	if err != nil {
+		log.Error(err, "failed to sync networkAttachmentDefinition")
+		return ctrl.Result{}, err
+	}
 
-		if len(nicsList.Items) == 0 {
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
-			if err != nil {
-				log.Error(err, "unable to construct networkInterface from privateNetwork")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			ip, err := r.IPAM.AcquireIP(prefix.Cidr)
-			if err != nil {
-				log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
+		},
+	)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("could not list NetworkInterface for privateNetwork %s", pn.Name))
+		return ctrl.Result{}, err
+	}
 
-			// TODO have a better idea :D
-			nic.Spec.Address = ip.IP.String() + "/" + strings.Split(prefix.Cidr, "/")[1]
-			nic.Spec.ID = privateNIC.ID
-			err = r.Client.Create(ctx, nic)
-			if err != nil {
-				log.Error(err, "could not create networkInterface")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			patch := client.MergeFrom(nic.DeepCopy())
-			nic.Status.MacAddress = privateNIC.MacAddress
-			err = r.Client.Status().Patch(ctx, nic, patch)
-			if err != nil {
-				log.Error(err, "could not patch networkInterface status")
-				return ctrl.Result{RequeueAfter: RequeueDuration}, err
-			}
-			log.Info(fmt.Sprintf("Successfully created networkInterface %s on node %s", nic.Name, node.Name))
-		}
+	err = r.syncDNS(ctx, pn, nicsList.Items)
+	if err != nil {
+		log.Error(err, "failed to sync dns records")
+		r.Recorder.Event(pn, corev1.EventTypeWarning, events.ReasonDNSSyncFailed, err.Error())
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
 	}
 
-	return ctrl.Result{}, nil
+	// the credentials are checked again periodically, in case they are revoked
+	return ctrl.Result{RequeueAfter: DriftCheckDuration}, nil
 }
 
-func (r *PrivateNetworkReconciler) constructNetworkInterfaceForPrivateNetwork(pn *vpcv1alpha1.PrivateNetwork, nodeName string) (*vpcv1alpha1.NetworkInterface, error) {
-	nic := &vpcv1alpha1.NetworkInterface{
-		ObjectMeta: metav1.ObjectMeta{
-			Labels:       make(map[string]string),
-			Annotations:  make(map[string]string),
-			GenerateName: pn.Name + "-",
-		},
-		Spec: vpcv1alpha1.NetworkInterfaceSpec{
-			NodeName: nodeName,
-		},
-	}
-	for k, v := range pn.Annotations {
-		nic.Annotations[k] = v
-	}
-	for k, v := range pn.Labels {
-		nic.Labels[k] = v
+// setCredentialsValid updates the CredentialsValid condition of the PrivateNetwork
+func (r *PrivateNetworkReconciler) setCredentialsValid(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork, status corev1.ConditionStatus, reason string, message string) error {
+	existing := vpcv1alpha1.FindCondition(pn.Status.Conditions, vpcv1alpha1.PrivateNetworkCredentialsValid)
+	if existing != nil && existing.Status == status && existing.Reason == reason && existing.Message == message {
+		return nil
 	}
-	nic.Labels[constants.PrivateNetworkLabel] = pn.Name
-	nic.Labels[constants.NodeLabel] = nodeName
-	if err := ctrl.SetControllerReference(pn, nic, r.Scheme); err != nil {
-		return nil, err
-	}
-	controllerutil.AddFinalizer(nic, constants.FinalizerName)
-	controllerutil.AddFinalizer(nic, constants.IPFinalizerName)
 
-	return nic, nil
+	patch := client.MergeFrom(pn.DeepCopy())
+	vpcv1alpha1.SetCondition(&pn.Status.Conditions, vpcv1alpha1.Condition{
+		Type:    vpcv1alpha1.PrivateNetworkCredentialsValid,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	return r.Status().Patch(ctx, pn, patch)
 }
 
 func (r *PrivateNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&vpcv1alpha1.PrivateNetwork{}).
 		Owns(&vpcv1alpha1.NetworkInterface{}).
-		Watches(&source.Kind{
-			Type: &corev1.Node{},
-		}, &handler.Funcs{
-			CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
-				pnsList := &vpcv1alpha1.PrivateNetworkList{}
-				err := r.Client.List(context.Background(), pnsList)
-				if err != nil {
-					r.Log.Error(err, "unable to sync privatenetwork on node creation")
-					return
-				}
-				for _, pn := range pnsList.Items {
-					q.Add(reconcile.Request{
-						NamespacedName: types.NamespacedName{
-							Name: pn.Name,
-						},
-					})
-				}
-			},
-		}).
 		Complete(r)
 }
@@ -0,0 +1,231 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
+	vpcv2 "github.com/scaleway/scaleway-sdk-go/api/vpc/v2"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// scwPrivateNetwork is a Scaleway private network, zonal or regional
+type scwPrivateNetwork struct {
+	ID   string
+	Name string
+	Tags []string
+}
+
+// privateNetworksAPI manages the Scaleway private networks, with the zonal (vpc/v1) or the regional (vpc/v2) API
+type privateNetworksAPI interface {
+	Get(id string) (*scwPrivateNetwork, error)
+	ListByTags(tags []string) ([]*scwPrivateNetwork, error)
+	Create(name string, projectID string, tags []string) (*scwPrivateNetwork, error)
+	Update(id string, name string, tags []string) (*scwPrivateNetwork, error)
+	Delete(id string) error
+}
+
+// newPrivateNetworksAPI returns the API of the private network of the PrivateNetwork
+func newPrivateNetworksAPI(scwClient *scw.Client, pn *vpcv1alpha1.PrivateNetwork) privateNetworksAPI {
+	if pn.IsRegional() {
+		return &regionalPrivateNetworksAPI{
+			api:    vpcv2.NewAPI(scwClient),
+			region: scw.Region(pn.Spec.Region),
+		}
+	}
+	return &zonalPrivateNetworksAPI{
+		api:  vpc.NewAPI(scwClient),
+		zone: scw.Zone(pn.Spec.Zone),
+	}
+}
+
+type zonalPrivateNetworksAPI struct {
+	api *vpc.API
+	// zone is the zone of the private network, the empty zone is the default zone of the client
+	zone scw.Zone
+}
+
+func fromZonal(privateNetwork *vpc.PrivateNetwork) *scwPrivateNetwork {
+	return &scwPrivateNetwork{
+		ID:   privateNetwork.ID,
+		Name: privateNetwork.Name,
+		Tags: privateNetwork.Tags,
+	}
+}
+
+func (z *zonalPrivateNetworksAPI) Get(id string) (*scwPrivateNetwork, error) {
+	privateNetwork, err := z.api.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
+		Zone:             z.zone,
+		PrivateNetworkID: id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromZonal(privateNetwork), nil
+}
+
+func (z *zonalPrivateNetworksAPI) ListByTags(tags []string) ([]*scwPrivateNetwork, error) {
+	resp, err := z.api.ListPrivateNetworks(&vpc.ListPrivateNetworksRequest{
+		Zone: z.zone,
+		Tags: tags,
+	}, scw.WithAllPages())
+	if err != nil {
+		return nil, err
+	}
+	privateNetworks := make([]*scwPrivateNetwork, 0, len(resp.PrivateNetworks))
+	for _, privateNetwork := range resp.PrivateNetworks {
+		privateNetworks = append(privateNetworks, fromZonal(privateNetwork))
+	}
+	return privateNetworks, nil
+}
+
+func (z *zonalPrivateNetworksAPI) Create(name string, projectID string, tags []string) (*scwPrivateNetwork, error) {
+	// the empty project is the default project of the client
+	privateNetwork, err := z.api.CreatePrivateNetwork(&vpc.CreatePrivateNetworkRequest{
+		Zone:      z.zone,
+		Name:      name,
+		ProjectID: projectID,
+		Tags:      tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromZonal(privateNetwork), nil
+}
+
+func (z *zonalPrivateNetworksAPI) Update(id string, name string, tags []string) (*scwPrivateNetwork, error) {
+	privateNetwork, err := z.api.UpdatePrivateNetwork(&vpc.UpdatePrivateNetworkRequest{
+		Zone:             z.zone,
+		PrivateNetworkID: id,
+		Name:             scw.StringPtr(name),
+		Tags:             &tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromZonal(privateNetwork), nil
+}
+
+func (z *zonalPrivateNetworksAPI) Delete(id string) error {
+	return z.api.DeletePrivateNetwork(&vpc.DeletePrivateNetworkRequest{
+		Zone:             z.zone,
+		PrivateNetworkID: id,
+	})
+}
+
+type regionalPrivateNetworksAPI struct {
+	api    *vpcv2.API
+	region scw.Region
+}
+
+func fromRegional(privateNetwork *vpcv2.PrivateNetwork) *scwPrivateNetwork {
+	return &scwPrivateNetwork{
+		ID:   privateNetwork.ID,
+		Name: privateNetwork.Name,
+		Tags: privateNetwork.Tags,
+	}
+}
+
+func (r *regionalPrivateNetworksAPI) Get(id string) (*scwPrivateNetwork, error) {
+	privateNetwork, err := r.api.GetPrivateNetwork(&vpcv2.GetPrivateNetworkRequest{
+		Region:           r.region,
+		PrivateNetworkID: id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromRegional(privateNetwork), nil
+}
+
+func (r *regionalPrivateNetworksAPI) ListByTags(tags []string) ([]*scwPrivateNetwork, error) {
+	resp, err := r.api.ListPrivateNetworks(&vpcv2.ListPrivateNetworksRequest{
+		Region: r.region,
+		Tags:   tags,
+	}, scw.WithAllPages())
+	if err != nil {
+		return nil, err
+	}
+	privateNetworks := make([]*scwPrivateNetwork, 0, len(resp.PrivateNetworks))
+	for _, privateNetwork := range resp.PrivateNetworks {
+		privateNetworks = append(privateNetworks, fromRegional(privateNetwork))
+	}
+	return privateNetworks, nil
+}
+
+func (r *regionalPrivateNetworksAPI) Create(name string, projectID string, tags []string) (*scwPrivateNetwork, error) {
+	// the empty project is the default project of the client
+	privateNetwork, err := r.api.CreatePrivateNetwork(&vpcv2.CreatePrivateNetworkRequest{
+		Region:    r.region,
+		Name:      name,
+		ProjectID: projectID,
+		Tags:      tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromRegional(privateNetwork), nil
+}
+
+func (r *regionalPrivateNetworksAPI) Update(id string, name string, tags []string) (*scwPrivateNetwork, error) {
+	privateNetwork, err := r.api.UpdatePrivateNetwork(&vpcv2.UpdatePrivateNetworkRequest{
+		Region:           r.region,
+		PrivateNetworkID: id,
+		Name:             scw.StringPtr(name),
+		Tags:             &tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromRegional(privateNetwork), nil
+}
+
+func (r *regionalPrivateNetworksAPI) Delete(id string) error {
+	return r.api.DeletePrivateNetwork(&vpcv2.DeletePrivateNetworkRequest{
+		Region:           r.region,
+		PrivateNetworkID: id,
+	})
+}
+
+// privateNetworkZones returns the zones the servers attached to the PrivateNetwork can be in,
+// defaultZone is used for zonal PrivateNetworks without a zone
+func privateNetworkZones(pn *vpcv1alpha1.PrivateNetwork, defaultZone scw.Zone) []scw.Zone {
+	if pn.IsRegional() {
+		return scw.Region(pn.Spec.Region).GetZones()
+	}
+	if pn.Spec.Zone != "" {
+		return []scw.Zone{scw.Zone(pn.Spec.Zone)}
+	}
+	return []scw.Zone{defaultZone}
+}
+
+// checkServerZone checks that the server can be attached to the private network of the PrivateNetwork
+func checkServerZone(scwClient *scw.Client, pn *vpcv1alpha1.PrivateNetwork, server *instance.Server) error {
+	defaultZone, _ := scwClient.GetDefaultZone()
+	for _, zone := range privateNetworkZones(pn, defaultZone) {
+		if zone == server.Zone {
+			return nil
+		}
+	}
+	if pn.IsRegional() {
+		return fmt.Errorf("server %s is in zone %s, outside of region %s", server.ID, server.Zone, pn.Spec.Region)
+	}
+	return fmt.Errorf("server %s is in zone %s, outside of the zone of the private network", server.ID, server.Zone)
+}
@@ -0,0 +1,383 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+
+	"github.com/go-logr/logr"
+	goipam "github.com/metal-stack/go-ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
+)
+
+// ServiceReconciler exposes the LoadBalancer Services annotated with a PrivateNetwork on it:
+// it acquires their address from the static IPAM of the PrivateNetwork, and elects the node announcing it
+type ServiceReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	IPAM     goipam.Ipamer
+	// Cache reads the Services as unstructured objects from an informer,
+	// the client sends the unstructured reads to the API server
+	Cache client.Reader
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=services/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("service", req.NamespacedName)
+
+	svc := &corev1.Service{}
+	err := r.Client.Get(ctx, req.NamespacedName, svc)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	pnName := svc.Annotations[constants.PrivateNetworkServiceAnnotation]
+	claimed := svc.Spec.Type == corev1.ServiceTypeLoadBalancer && pnName != "" && svc.ObjectMeta.GetDeletionTimestamp().IsZero()
+	if claimed {
+		class, err := r.loadBalancerClass(ctx, req.NamespacedName)
+		if err != nil {
+			// the unstructured informer may not have seen the Service yet
+			log.Error(err, "unable to get load balancer class")
+			return ctrl.Result{}, err
+		}
+		if class != constants.LoadBalancerClass {
+			// without the class, the cloud controller manager also exposes the Service and writes its status
+			claimed = false
+			r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonInvalidSpec, "Service must set spec.loadBalancerClass to %s to be exposed on private network %s", constants.LoadBalancerClass, pnName)
+		}
+	}
+	if !claimed {
+		if !controllerutil.ContainsFinalizer(svc, constants.ServiceFinalizerName) {
+			return ctrl.Result{}, nil
+		}
+		err := r.releaseAddress(ctx, svc)
+		if err != nil {
+			log.Error(err, "unable to release address")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	pn := vpcv1alpha1.PrivateNetwork{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: pnName}, &pn)
+	if err != nil {
+		log.Error(err, "unable to get private network")
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonPrivateNetworkNotFound, "Private network %s not found", pnName)
+			return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeStatic || pn.Spec.IPAM.Static == nil {
+		r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonInvalidSpec, "Private network %s has no static IPAM", pnName)
+		return ctrl.Result{}, nil
+	}
+
+	if svc.Annotations[constants.AllocatedAddressAnnotation] == "" {
+		err := r.acquireAddress(ctx, log, svc, &pn)
+		if err != nil {
+			log.Error(err, "unable to acquire address")
+			return ctrl.Result{RequeueAfter: RequeueDuration}, err
+		}
+	}
+	address := svc.Annotations[constants.AllocatedAddressAnnotation]
+
+	if len(svc.Status.LoadBalancer.Ingress) != 1 || svc.Status.LoadBalancer.Ingress[0].IP != address {
+		patch := client.MergeFrom(svc.DeepCopy())
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: address}}
+		err := r.Client.Status().Patch(ctx, svc, patch)
+		if err != nil {
+			log.Error(err, "unable to patch service status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	nodes, err := r.eligibleNodes(ctx, &pn)
+	if err != nil {
+		log.Error(err, "unable to list eligible nodes")
+		return ctrl.Result{}, err
+	}
+	announcingNode := electNode(svc, nodes)
+	if announcingNode != svc.Annotations[constants.AnnouncingNodeAnnotation] {
+		patch := client.MergeFrom(svc.DeepCopy())
+		if announcingNode == "" {
+			delete(svc.Annotations, constants.AnnouncingNodeAnnotation)
+		} else {
+			svc.Annotations[constants.AnnouncingNodeAnnotation] = announcingNode
+		}
+		err := r.Client.Patch(ctx, svc, patch)
+		if err != nil {
+			log.Error(err, "unable to patch announcing node")
+			return ctrl.Result{}, err
+		}
+		if announcingNode != "" {
+			r.Recorder.Eventf(svc, corev1.EventTypeNormal, events.ReasonAddressAnnounced, "Address %s announced by node %s", address, announcingNode)
+		}
+	}
+	if announcingNode == "" {
+		r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonAddressAnnounced, "No ready node to announce address %s", address)
+		return ctrl.Result{RequeueAfter: RequeueDuration}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// loadBalancerClass returns the spec.loadBalancerClass of the Service, which the core/v1 types in use don't have
+func (r *ServiceReconciler) loadBalancerClass(ctx context.Context, key types.NamespacedName) (string, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+	err := r.Cache.Get(ctx, key, u)
+	if err != nil {
+		return "", err
+	}
+	class, _, err := unstructured.NestedString(u.Object, "spec", "loadBalancerClass")
+	return class, err
+}
+
+// acquireAddress acquires the address of the Service from the static IPAM of the PrivateNetwork,
+// spec.loadBalancerIP is honored if set
+func (r *ServiceReconciler) acquireAddress(ctx context.Context, log logr.Logger, svc *corev1.Service, pn *vpcv1alpha1.PrivateNetwork) error {
+	cidrs := staticCIDRs(pn.Spec.IPAM.Static)
+
+	var ip *goipam.IP
+	var chosenCidr string
+	if svc.Spec.LoadBalancerIP != "" {
+		requested := net.ParseIP(svc.Spec.LoadBalancerIP)
+		for _, cidr := range cidrs {
+			_, ipnet, err := net.ParseCIDR(cidr)
+			if err != nil || requested == nil || !ipnet.Contains(requested) {
+				continue
+			}
+			prefix, err := r.IPAM.NewPrefix(cidr)
+			if err != nil {
+				return err
+			}
+			ip, err = r.IPAM.AcquireSpecificIP(prefix.Cidr, requested.String())
+			if err != nil {
+				r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonIPAllocationFailed, "Could not acquire IP %s: %s", svc.Spec.LoadBalancerIP, err)
+				return err
+			}
+			chosenCidr = prefix.Cidr
+			break
+		}
+	} else {
+		ip, chosenCidr = acquireIP(log, r.IPAM, cidrs)
+	}
+	if ip == nil {
+		r.Recorder.Eventf(svc, corev1.EventTypeWarning, events.ReasonIPAllocationFailed, "Could not acquire IP in private network %s", pn.Name)
+		return fmt.Errorf("could not acquire IP")
+	}
+
+	patch := client.MergeFrom(svc.DeepCopy())
+	controllerutil.AddFinalizer(svc, constants.ServiceFinalizerName)
+	svc.Annotations[constants.AllocatedAddressAnnotation] = ip.IP.String()
+	svc.Annotations[constants.AllocatedFromAnnotation] = chosenCidr
+	err := r.Client.Patch(ctx, svc, patch)
+	if err != nil {
+		ipamErr := r.IPAM.ReleaseIPFromPrefix(chosenCidr, ip.IP.String())
+		if ipamErr != nil {
+			log.Error(ipamErr, fmt.Sprintf("failed to release IP %s", ip.IP.String()))
+		}
+		return err
+	}
+	r.Recorder.Eventf(svc, corev1.EventTypeNormal, events.ReasonIPAllocated, "Allocated IP %s from %s", ip.IP.String(), chosenCidr)
+	return nil
+}
+
+// releaseAddress releases the address of the Service, and stops exposing it
+func (r *ServiceReconciler) releaseAddress(ctx context.Context, svc *corev1.Service) error {
+	address := svc.Annotations[constants.AllocatedAddressAnnotation]
+	cidr := svc.Annotations[constants.AllocatedFromAnnotation]
+	if address != "" && cidr != "" {
+		err := r.IPAM.ReleaseIPFromPrefix(cidr, address)
+		if err != nil && !errors.As(err, &goipam.NotFoundError{}) {
+			return err
+		}
+		r.Recorder.Eventf(svc, corev1.EventTypeNormal, events.ReasonIPReleased, "Released IP %s from %s", address, cidr)
+	}
+
+	if svc.ObjectMeta.GetDeletionTimestamp().IsZero() && svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		patch := client.MergeFrom(svc.DeepCopy())
+		svc.Status.LoadBalancer.Ingress = nil
+		err := r.Client.Status().Patch(ctx, svc, patch)
+		if err != nil {
+			return err
+		}
+	}
+
+	patch := client.MergeFrom(svc.DeepCopy())
+	delete(svc.Annotations, constants.AllocatedAddressAnnotation)
+	delete(svc.Annotations, constants.AllocatedFromAnnotation)
+	delete(svc.Annotations, constants.AnnouncingNodeAnnotation)
+	controllerutil.RemoveFinalizer(svc, constants.ServiceFinalizerName)
+	return r.Client.Patch(ctx, svc, patch)
+}
+
+// eligibleNodes returns the sorted names of the ready nodes with a Ready NetworkInterface in the PrivateNetwork
+func (r *ServiceReconciler) eligibleNodes(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork) ([]string, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pn.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []string{}
+	for _, nic := range nicsList.Items {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() || nic.Status.Phase != vpcv1alpha1.NetworkInterfacePhaseReady {
+			continue
+		}
+		node := corev1.Node{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: nic.Spec.NodeName}, &node)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if node.ObjectMeta.GetDeletionTimestamp().IsZero() && isNodeReady(&node) {
+			nodes = append(nodes, node.Name)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// electNode returns the node announcing the address of the Service. The current node is kept while
+// it's eligible, otherwise the Services are spread over the eligible nodes.
+func electNode(svc *corev1.Service, nodes []string) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	current := svc.Annotations[constants.AnnouncingNodeAnnotation]
+	for _, node := range nodes {
+		if node == current {
+			return current
+		}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(svc.Namespace + "/" + svc.Name))
+	return nodes[h.Sum32()%uint32(len(nodes))]
+}
+
+// servicesForPrivateNetwork returns the requests of the Services exposed on the PrivateNetwork,
+// or on any PrivateNetwork if pnName is empty
+func (r *ServiceReconciler) servicesForPrivateNetwork(pnName string) []reconcile.Request {
+	svcsList := &corev1.ServiceList{}
+	err := r.Client.List(context.Background(), svcsList)
+	if err != nil {
+		r.Log.Error(err, "unable to list services")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, svc := range svcsList.Items {
+		name, ok := svc.Annotations[constants.PrivateNetworkServiceAnnotation]
+		if !ok || (pnName != "" && name != pnName) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: svc.Namespace,
+				Name:      svc.Name,
+			},
+		})
+	}
+	return requests
+}
+
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Service{}).
+		Watches(&source.Kind{
+			Type: &vpcv1alpha1.PrivateNetwork{},
+		}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				return r.servicesForPrivateNetwork(o.Meta.GetName())
+			}),
+		}).
+		Watches(&source.Kind{
+			Type: &vpcv1alpha1.NetworkInterface{},
+		}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				pnName := o.Meta.GetLabels()[constants.PrivateNetworkLabel]
+				if pnName == "" {
+					return nil
+				}
+				return r.servicesForPrivateNetwork(pnName)
+			}),
+		}).
+		Watches(&source.Kind{
+			Type: &corev1.Node{},
+		}, &handler.Funcs{
+			// the address fails over when the announcing node is not ready anymore
+			UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+				oldNode, ok := e.ObjectOld.(*corev1.Node)
+				if !ok {
+					return
+				}
+				newNode, ok := e.ObjectNew.(*corev1.Node)
+				if !ok {
+					return
+				}
+				if isNodeReady(oldNode) == isNodeReady(newNode) {
+					return
+				}
+				for _, req := range r.servicesForPrivateNetwork("") {
+					q.Add(req)
+				}
+			},
+			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+				for _, req := range r.servicesForPrivateNetwork("") {
+					q.Add(req)
+				}
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,168 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// PrivateNICSweeper periodically deletes the private NICs tagged with the cluster ID
+// that are not backed by any NetworkInterface anymore
+type PrivateNICSweeper struct {
+	client.Client
+	Log       logr.Logger
+	Scaleway  *ScalewayClients
+	ClusterID string
+	Interval  time.Duration
+
+	// candidates are the private NICs found orphaned during the previous sweep.
+	// A private NIC is only deleted when it is found orphaned twice in a row, so that
+	// a NIC created right before its NetworkInterface is not removed.
+	candidates map[string]struct{}
+}
+
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// Start runs the sweeper until the stop channel is closed
+func (s *PrivateNICSweeper) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			err := s.Sweep(context.Background())
+			if err != nil {
+				s.Log.Error(err, "unable to sweep private nics")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader sweeps the private NICs
+func (s *PrivateNICSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Sweep deletes the orphaned private NICs in every zone used by a PrivateNetwork,
+// with the client of the default credentials and the clients of the credentials Secrets
+func (s *PrivateNICSweeper) Sweep(ctx context.Context) error {
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := s.Client.List(ctx, pnsList)
+	if err != nil {
+		return fmt.Errorf("could not list privateNetworks: %w", err)
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = s.Client.List(ctx, nicsList)
+	if err != nil {
+		return fmt.Errorf("could not list networkInterfaces: %w", err)
+	}
+
+	knownNICs := make(map[string]struct{}, len(nicsList.Items))
+	for _, nic := range nicsList.Items {
+		knownNICs[nic.Spec.ID] = struct{}{}
+	}
+
+	// the zones to sweep with each distinct client, the empty zone is the default zone of the client
+	clientsZones := make(map[*scw.Client]map[scw.Zone]struct{})
+	if scwClient := s.Scaleway.Default(); scwClient != nil {
+		clientsZones[scwClient] = map[scw.Zone]struct{}{"": {}}
+	}
+	for _, pn := range pnsList.Items {
+		scwClient, err := s.Scaleway.ForPrivateNetwork(ctx, &pn)
+		if err != nil {
+			s.Log.Error(err, fmt.Sprintf("could not get scaleway client of privateNetwork %s", pn.Name))
+			continue
+		}
+		zones, ok := clientsZones[scwClient]
+		if !ok {
+			zones = map[scw.Zone]struct{}{"": {}}
+			clientsZones[scwClient] = zones
+		}
+		for _, zone := range privateNetworkZones(&pn, "") {
+			zones[zone] = struct{}{}
+		}
+	}
+	if len(clientsZones) == 0 {
+		return fmt.Errorf("the scaleway credentials of the controller are not loaded")
+	}
+
+	candidates := make(map[string]struct{})
+	for scwClient, zones := range clientsZones {
+		s.sweepZones(instance.NewAPI(scwClient), zones, knownNICs, candidates)
+	}
+
+	s.candidates = candidates
+	return nil
+}
+
+// sweepZones deletes the orphaned private NICs found in the zones, and records the new candidates
+func (s *PrivateNICSweeper) sweepZones(instanceAPI *instance.API, zones map[scw.Zone]struct{}, knownNICs map[string]struct{}, candidates map[string]struct{}) {
+	clusterTag := constants.ClusterIDTagPrefix + s.ClusterID
+
+	for zone := range zones {
+		serversResp, err := instanceAPI.ListServers(&instance.ListServersRequest{
+			Zone: zone,
+		}, scw.WithAllPages())
+		if err != nil {
+			s.Log.Error(err, fmt.Sprintf("could not list servers in zone %s", zone))
+			continue
+		}
+
+		for _, server := range serversResp.Servers {
+			for _, pnic := range server.PrivateNics {
+				if !hasTag(pnic.Tags, clusterTag) || hasTag(pnic.Tags, constants.OrphanedTag) {
+					continue
+				}
+				if _, ok := knownNICs[pnic.ID]; ok {
+					continue
+				}
+				if _, ok := s.candidates[pnic.ID]; !ok {
+					candidates[pnic.ID] = struct{}{}
+					continue
+				}
+
+				err := instanceAPI.DeletePrivateNIC(&instance.DeletePrivateNICRequest{
+					Zone:         server.Zone,
+					ServerID:     server.ID,
+					PrivateNicID: pnic.ID,
+				})
+				if err != nil {
+					s.Log.Error(err, fmt.Sprintf("could not delete orphaned private nic %s on server %s", pnic.ID, server.ID))
+					candidates[pnic.ID] = struct{}{}
+					continue
+				}
+				s.Log.Info(fmt.Sprintf("Successfully deleted orphaned private nic %s on server %s", pnic.ID, server.ID))
+			}
+		}
+	}
+}
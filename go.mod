@@ -8,8 +8,10 @@ require (
 	github.com/metal-stack/go-ipam v1.8.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
-	github.com/scaleway/scaleway-sdk-go v1.0.0-beta.7.0.20210223165440-c65ae3540d44
+	github.com/prometheus/client_golang v1.0.0
+	github.com/scaleway/scaleway-sdk-go v1.0.0-beta.22
 	github.com/vishvananda/netlink v1.1.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 	google.golang.org/appengine v1.6.6 // indirect
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
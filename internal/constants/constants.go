@@ -12,4 +12,47 @@ const (
 
 	// NodeLabel is the node label
 	NodeLabel = "node"
+
+	// ClusterIDTagPrefix is the prefix of the Scaleway tag holding the cluster ID on private NICs
+	ClusterIDTagPrefix = "k8s-vpc-cluster="
+
+	// AdoptPrivateNICsAnnotation allows a PrivateNetwork to adopt the private NICs not created by this controller
+	AdoptPrivateNICsAnnotation = "vpc.scaleway.com/adopt-private-nics"
+
+	// OrphanAnnotation is set on the NetworkInterfaces whose private NIC and link are left in place on deletion
+	OrphanAnnotation = "vpc.scaleway.com/orphan"
+
+	// OrphanedTag is the Scaleway tag set on the private NICs left in place, until they are adopted again
+	OrphanedTag = "k8s-vpc-orphaned"
+
+	// PrivateNetworkTagPrefix is the prefix of the Scaleway tag holding the PrivateNetwork name on private NICs
+	PrivateNetworkTagPrefix = "k8s-vpc-private-network="
+
+	// NotReadyTaintKey is the key of the taint set on the nodes until their required PrivateNetworks are configured
+	NotReadyTaintKey = "vpc.scaleway.com/not-ready"
+
+	// AdvertisedAddressesAnnotation holds the node addresses added by the node daemon
+	AdvertisedAddressesAnnotation = "vpc.scaleway.com/advertised-addresses"
+
+	// IPAMPluginName is the name of the CNI IPAM plugin giving pods an address in a PrivateNetwork
+	IPAMPluginName = "scaleway-k8s-vpc-ipam"
+
+	// ServiceFinalizerName is the name of the finalizer releasing the address of the LoadBalancer Services
+	ServiceFinalizerName = "scaleway.com/finalizer-service"
+
+	// PrivateNetworkServiceAnnotation exposes a LoadBalancer Service on the given PrivateNetwork
+	PrivateNetworkServiceAnnotation = "vpc.scaleway.com/private-network"
+
+	// LoadBalancerClass is the spec.loadBalancerClass of the Services exposed on a PrivateNetwork,
+	// so that the cloud controller manager doesn't create a public load balancer for them
+	LoadBalancerClass = "vpc.scaleway.com/private-network"
+
+	// AllocatedAddressAnnotation holds the address acquired for a LoadBalancer Service
+	AllocatedAddressAnnotation = "vpc.scaleway.com/allocated-address"
+
+	// AllocatedFromAnnotation holds the prefix the address of a LoadBalancer Service was acquired from
+	AllocatedFromAnnotation = "vpc.scaleway.com/allocated-from"
+
+	// AnnouncingNodeAnnotation holds the node announcing the address of a LoadBalancer Service
+	AnnouncingNodeAnnotation = "vpc.scaleway.com/announcing-node"
 )
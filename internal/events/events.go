@@ -0,0 +1,80 @@
+package events
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ReasonAttached is the reason of the event emitted when a node is attached to a PrivateNetwork
+	ReasonAttached = "Attached"
+	// ReasonDetached is the reason of the event emitted when a node is detached from a PrivateNetwork
+	ReasonDetached = "Detached"
+	// ReasonServerNotFound is the reason of the event emitted when the server of a node can't be found
+	ReasonServerNotFound = "ServerNotFound"
+	// ReasonPrivateNICUnavailable is the reason of the event emitted when a private NIC can't be used
+	ReasonPrivateNICUnavailable = "PrivateNICUnavailable"
+	// ReasonPrivateNICRecreated is the reason of the event emitted when a missing private NIC is recreated
+	ReasonPrivateNICRecreated = "PrivateNICRecreated"
+	// ReasonPrivateNetworkCreated is the reason of the event emitted when a managed private network is created
+	ReasonPrivateNetworkCreated = "PrivateNetworkCreated"
+	// ReasonPrivateNetworkDeleted is the reason of the event emitted when a managed private network is deleted
+	ReasonPrivateNetworkDeleted = "PrivateNetworkDeleted"
+	// ReasonPrivateNetworkRetained is the reason of the event emitted when a managed private network is not deleted
+	ReasonPrivateNetworkRetained = "PrivateNetworkRetained"
+	// ReasonPrivateNetworkNotFound is the reason of the event emitted when the private network can't be found
+	ReasonPrivateNetworkNotFound = "PrivateNetworkNotFound"
+	// ReasonInvalidCredentials is the reason of the event emitted when the Scaleway credentials of a PrivateNetwork can't be used
+	ReasonInvalidCredentials = "InvalidCredentials"
+	// ReasonInvalidSpec is the reason of the event emitted when a PrivateNetwork spec is invalid
+	ReasonInvalidSpec = "InvalidSpec"
+	// ReasonIPAllocated is the reason of the event emitted when an IP is allocated
+	ReasonIPAllocated = "IPAllocated"
+	// ReasonIPAllocationFailed is the reason of the event emitted when no IP can be allocated
+	ReasonIPAllocationFailed = "IPAllocationFailed"
+	// ReasonIPReleased is the reason of the event emitted when an IP is released
+	ReasonIPReleased = "IPReleased"
+	// ReasonPodCIDRAllocated is the reason of the event emitted when a pod CIDR is allocated to a node
+	ReasonPodCIDRAllocated = "PodCIDRAllocated"
+	// ReasonPodCIDRReleased is the reason of the event emitted when the pod CIDR of a node is released
+	ReasonPodCIDRReleased = "PodCIDRReleased"
+	// ReasonDNSSyncFailed is the reason of the event emitted when the DNS records of a PrivateNetwork can't be published
+	ReasonDNSSyncFailed = "DNSSyncFailed"
+	// ReasonIngressRulesSynced is the reason of the event emitted when the ingress rules of a link change
+	ReasonIngressRulesSynced = "IngressRulesSynced"
+	// ReasonAddressAnnounced is the reason of the event emitted when a node starts announcing the address of a Service
+	ReasonAddressAnnounced = "AddressAnnounced"
+	// ReasonLinkConfigured is the reason of the event emitted when a link is configured on a node
+	ReasonLinkConfigured = "LinkConfigured"
+	// ReasonLinkConfigurationFailed is the reason of the event emitted when a link can't be configured
+	ReasonLinkConfigurationFailed = "LinkConfigurationFailed"
+	// ReasonLinkTornDown is the reason of the event emitted when a link is torn down on a node
+	ReasonLinkTornDown = "LinkTornDown"
+	// ReasonLinkOrphaned is the reason of the event emitted when a link is left configured on a node
+	ReasonLinkOrphaned = "LinkOrphaned"
+	// ReasonPrivateNICOrphaned is the reason of the event emitted when a private NIC is left attached to a server
+	ReasonPrivateNICOrphaned = "PrivateNICOrphaned"
+	// ReasonDuplicateNetworkInterface is the reason of the event emitted when a node has several NetworkInterfaces
+	// for the same PrivateNetwork, only the oldest one is used
+	ReasonDuplicateNetworkInterface = "DuplicateNetworkInterface"
+	// ReasonNICNotFound is the reason of the event emitted when a NIC is not found on a node
+	ReasonNICNotFound = "NICNotFound"
+	// ReasonDHCPLease is the reason of the event emitted when a link gets a new address from DHCP
+	ReasonDHCPLease = "DHCPLease"
+	// ReasonRoutesSynced is the reason of the event emitted when the routes of a link change
+	ReasonRoutesSynced = "RoutesSynced"
+	// ReasonMasqueradeEnabled is the reason of the event emitted when the masquerade rule is added
+	ReasonMasqueradeEnabled = "MasqueradeEnabled"
+	// ReasonMasqueradeDisabled is the reason of the event emitted when the masquerade rule is removed
+	ReasonMasqueradeDisabled = "MasqueradeDisabled"
+)
+
+// NodeRef returns a reference to the node to attach events to.
+// As the kubelet does, the UID is set to the node name.
+func NodeRef(nodeName string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind: "Node",
+		Name: nodeName,
+		UID:  types.UID(nodeName),
+	}
+}
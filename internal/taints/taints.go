@@ -0,0 +1,47 @@
+package taints
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// NotReady is the taint set on the nodes until their required PrivateNetworks are configured
+var NotReady = corev1.Taint{
+	Key:    constants.NotReadyTaintKey,
+	Effect: corev1.TaintEffectNoSchedule,
+}
+
+// HasNotReady returns whether the node has the NotReady taint
+func HasNotReady(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.MatchTaint(&NotReady) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddNotReady adds the NotReady taint to the node, and returns whether the node was changed
+func AddNotReady(node *corev1.Node) bool {
+	if HasNotReady(node) {
+		return false
+	}
+	node.Spec.Taints = append(node.Spec.Taints, NotReady)
+	return true
+}
+
+// RemoveNotReady removes the NotReady taint from the node, and returns whether the node was changed
+func RemoveNotReady(node *corev1.Node) bool {
+	taints := []corev1.Taint{}
+	for _, taint := range node.Spec.Taints {
+		if !taint.MatchTaint(&NotReady) {
+			taints = append(taints, taint)
+		}
+	}
+	if len(taints) == len(node.Spec.Taints) {
+		return false
+	}
+	node.Spec.Taints = taints
+	return true
+}
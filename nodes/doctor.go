@@ -0,0 +1,266 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// Doctor compares the configuration of the links of the node with its NetworkInterfaces
+type Doctor struct {
+	// Client reads the NetworkInterfaces of the node, the checks against them are skipped if it's nil
+	Client      client.Reader
+	MetadataAPI *instance.MetadataAPI
+	NICs        *nics.NICs
+	State       *State
+	NodeName    string
+	Out         io.Writer
+
+	drift bool
+}
+
+// check prints the result of a check, and records a drift if it failed
+func (d *Doctor) check(ok bool, format string, args ...interface{}) {
+	status := "OK"
+	if !ok {
+		status = "DRIFT"
+		d.drift = true
+	}
+	fmt.Fprintf(d.Out, "  [%-5s] %s\n", status, fmt.Sprintf(format, args...))
+}
+
+// Run prints the state of the links of the node and returns whether a drift was found
+func (d *Doctor) Run(ctx context.Context) (bool, error) {
+	md, err := d.MetadataAPI.GetMetadata()
+	if err != nil {
+		return false, fmt.Errorf("unable to get metadata: %w", err)
+	}
+
+	fmt.Fprintf(d.Out, "Private NICs of node %s:\n", d.NodeName)
+	metadataMACs := make(map[string]string)
+	for _, pnic := range md.PrivateNICs {
+		metadataMACs[pnic.MacAddress] = pnic.PrivateNetworkID
+		linkName, err := d.NICs.GetLinkName(pnic.MacAddress)
+		d.check(err == nil, "private nic %s in private network %s with mac %s is link %s", pnic.ID, pnic.PrivateNetworkID, pnic.MacAddress, linkOrMissing(linkName, err))
+	}
+
+	ip, err := iptables.New()
+	if err != nil {
+		return d.drift, err
+	}
+
+	fmt.Fprintf(d.Out, "\nLinks of the state file:\n")
+	d.checkState(ip, metadataMACs)
+
+	if d.Client == nil {
+		fmt.Fprintf(d.Out, "\nSkipping the NetworkInterface checks without a kubeconfig\n")
+		return d.drift, nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = d.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: d.NodeName,
+	})
+	if err != nil {
+		return d.drift, fmt.Errorf("unable to list networkInterfaces: %w", err)
+	}
+	sort.Slice(nicsList.Items, func(i, j int) bool {
+		return nicsList.Items[i].Name < nicsList.Items[j].Name
+	})
+
+	for _, nic := range nicsList.Items {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := d.Client.Get(ctx, types.NamespacedName{Name: nic.Labels[constants.PrivateNetworkLabel]}, &pnet)
+		if err != nil {
+			return d.drift, fmt.Errorf("unable to get private network of networkInterface %s: %w", nic.Name, err)
+		}
+
+		fmt.Fprintf(d.Out, "\nNetworkInterface %s of private network %s:\n", nic.Name, pnet.Name)
+		d.checkNetworkInterface(ctx, ip, &nic, &pnet, metadataMACs)
+	}
+
+	return d.drift, nil
+}
+
+// checkState checks the links recorded in the state file, without the NetworkInterfaces
+func (d *Doctor) checkState(ip *iptables.IPTables, metadataMACs map[string]string) {
+	links := d.State.GetLinks()
+	macs := make([]string, 0, len(links))
+	for mac := range links {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+
+	for _, mac := range macs {
+		_, ok := metadataMACs[mac]
+		d.check(ok, "mac %s of link %s is in the metadata", mac, links[mac])
+
+		linkName, err := d.NICs.GetLinkName(mac)
+		d.check(err == nil && linkName == links[mac], "link %s with mac %s is %s", links[mac], mac, linkOrMissing(linkName, err))
+		if err != nil {
+			continue
+		}
+
+		up, err := d.NICs.IsUp(mac)
+		d.check(err == nil && up, "link %s is up", linkName)
+
+		addresses, err := d.NICs.GetAddresses(mac)
+		d.check(err == nil && len(addresses) > 0, "link %s has addresses %s", linkName, strings.Join(addresses, ", "))
+
+		routes, err := d.NICs.GetRoutes(mac)
+		if err != nil {
+			d.check(false, "unable to get routes of link %s: %s", linkName, err)
+		}
+		for _, route := range routes {
+			fmt.Fprintf(d.Out, "          route %s\n", routeString(route.To, route.Via))
+		}
+
+		masquerade, err := ip.Exists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+		if err != nil {
+			d.check(false, "unable to check masquerade iptables rule: %s", err)
+		} else {
+			fmt.Fprintf(d.Out, "          masquerade rule present: %t\n", masquerade)
+		}
+		ingress, err := ingressChainExists(ip, linkName)
+		if err != nil {
+			d.check(false, "unable to check ingress iptables chain: %s", err)
+		} else {
+			fmt.Fprintf(d.Out, "          ingress rules chain present: %t\n", ingress)
+		}
+	}
+}
+
+// checkNetworkInterface checks the link of the NetworkInterface
+func (d *Doctor) checkNetworkInterface(ctx context.Context, ip *iptables.IPTables, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, metadataMACs map[string]string) {
+	mac := nic.Status.MacAddress
+	d.check(nic.Status.Phase == vpcv1alpha1.NetworkInterfacePhaseReady, "phase is %s", nic.Status.Phase)
+
+	_, ok := metadataMACs[mac]
+	d.check(ok, "mac %s is in the metadata", mac)
+
+	linkName, err := d.NICs.GetLinkName(mac)
+	d.check(err == nil, "link is %s", linkOrMissing(linkName, err))
+	if err != nil {
+		return
+	}
+
+	desiredLinkName, err := pnet.RenderInterfaceName()
+	if err == nil && desiredLinkName != "" {
+		d.check(linkName == desiredLinkName, "link name is %s, expected %s", linkName, desiredLinkName)
+	}
+
+	up, err := d.NICs.IsUp(mac)
+	d.check(err == nil && up, "link is up")
+
+	addresses, err := d.NICs.GetAddresses(mac)
+	if err != nil {
+		d.check(false, "unable to get addresses: %s", err)
+		return
+	}
+	if pnet.Spec.IPAM != nil && pnet.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeDHCP {
+		running, err := d.NICs.IsDHCPRunning(mac)
+		d.check(err == nil && running, "dhcpcd is running")
+	}
+	expected := networkInterfaceAddress(nic, pnet)
+	d.check(hasAddress(addresses, expected), "address is %s, expected %s", strings.Join(addresses, ", "), expected)
+
+	routes, err := d.NICs.GetRoutes(mac)
+	if err != nil {
+		d.check(false, "unable to get routes: %s", err)
+		return
+	}
+	actualRoutes := make(map[string]struct{})
+	for _, route := range routes {
+		actualRoutes[routeString(route.To, route.Via)] = struct{}{}
+	}
+	expectedRoutes := make(map[string]struct{})
+	for _, route := range pnet.Spec.Routes {
+		to, err := netlink.ParseIPNet(route.To)
+		if err != nil {
+			d.check(false, "invalid route to %s: %s", route.To, err)
+			continue
+		}
+		r := routeString(to, net.ParseIP(route.Via))
+		expectedRoutes[r] = struct{}{}
+		_, ok := actualRoutes[r]
+		d.check(ok, "route %s", r)
+	}
+	podRoutes, err := peerPodRoutes(ctx, d.Client, nic, pnet)
+	if err != nil {
+		d.check(false, "unable to get pod cidrs of the peers: %s", err)
+	}
+	for _, route := range podRoutes {
+		r := routeString(route.To, route.Via)
+		expectedRoutes[r] = struct{}{}
+		_, ok := actualRoutes[r]
+		d.check(ok, "pod route %s", r)
+	}
+	for r := range actualRoutes {
+		if _, ok := expectedRoutes[r]; !ok {
+			d.check(false, "unexpected route %s", r)
+		}
+	}
+
+	masquerade, err := ip.Exists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	if err != nil {
+		d.check(false, "unable to check masquerade iptables rule: %s", err)
+		return
+	}
+	d.check(masquerade == pnet.Spec.Masquerade, "masquerade rule present: %t, expected %t", masquerade, pnet.Spec.Masquerade)
+}
+
+func linkOrMissing(linkName string, err error) string {
+	if err != nil {
+		return "missing"
+	}
+	return linkName
+}
+
+// hasAddress returns whether the expected address is in the addresses, the expected address
+// can be given with or without mask
+func hasAddress(addresses []string, expected string) bool {
+	expectedIP := strings.Split(expected, "/")[0]
+	for _, address := range addresses {
+		if address == expected || strings.Split(address, "/")[0] == expectedIP {
+			return true
+		}
+	}
+	return false
+}
+
+func routeString(to *net.IPNet, via net.IP) string {
+	return fmt.Sprintf("%s via %s", to, via)
+}
@@ -0,0 +1,214 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const (
+	// ingressChainPrefix is the prefix of the filter chains holding the ingress rules of the links,
+	// the chain names are limited to 28 characters
+	ingressChainPrefix = "VPC-INGRESS-"
+	// newIngressChainPrefix is the prefix of the chains the new ingress rules are rendered in,
+	// before replacing the chains of the links
+	newIngressChainPrefix = "VPC-INGNEW-"
+)
+
+// ingressChain returns the name of the chain holding the ingress rules of the link
+func ingressChain(linkName string) string {
+	return ingressChainPrefix + linkName
+}
+
+// newIngressChain returns the name of the chain the new ingress rules of the link are rendered in
+func newIngressChain(linkName string) string {
+	return newIngressChainPrefix + linkName
+}
+
+// ingressRuleSpecs renders the ingress rules into the rules of the chain of the link
+func ingressRuleSpecs(rules *vpcv1alpha1.PrivateNetworkIngressRules, dhcp bool) [][]string {
+	specs := [][]string{
+		{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+	}
+	if dhcp {
+		// the DHCP replies may be broadcasted, so they don't match the conntrack entries
+		specs = append(specs, []string{"-p", "udp", "--dport", "68", "-j", "ACCEPT"})
+	}
+
+	for _, rule := range rules.Rules {
+		sources := rule.From
+		if len(sources) == 0 {
+			sources = []string{""}
+		}
+		ports := rule.Ports
+		if len(ports) == 0 {
+			ports = []int32{0}
+		}
+		for _, source := range sources {
+			for _, port := range ports {
+				spec := []string{}
+				if source != "" {
+					spec = append(spec, "-s", source)
+				}
+				if rule.Protocol != "" {
+					spec = append(spec, "-p", strings.ToLower(string(rule.Protocol)))
+				}
+				if port != 0 {
+					spec = append(spec, "--dport", strconv.Itoa(int(port)))
+				}
+				specs = append(specs, append(spec, "-j", "ACCEPT"))
+			}
+		}
+	}
+
+	if rules.DefaultDeny {
+		specs = append(specs, []string{"-j", "DROP"})
+	}
+	return specs
+}
+
+// syncIngressRules renders the ingress rules of the PrivateNetwork in the chain of the link, and returns
+// whether the chain was changed. The chain is only rewritten when the rules change, by rendering the rules
+// in a new chain and swapping the jump to it, so that the link is never left without its rules.
+func (r *NetworkInterfaceReconciler) syncIngressRules(ip *iptables.IPTables, linkName string, pnet *vpcv1alpha1.PrivateNetwork) (bool, error) {
+	if pnet.Spec.IngressRules == nil {
+		exists, err := ingressChainExists(ip, linkName)
+		if err != nil || !exists {
+			return false, err
+		}
+		return true, r.removeIngressRules(ip, linkName)
+	}
+
+	dhcp := pnet.Spec.IPAM != nil && pnet.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeDHCP
+	specs := ingressRuleSpecs(pnet.Spec.IngressRules, dhcp)
+	rendered := fmt.Sprint(specs)
+	chain := ingressChain(linkName)
+
+	exists, err := ingressChainExists(ip, linkName)
+	if err != nil {
+		return false, err
+	}
+
+	r.ingressRulesLock.Lock()
+	defer r.ingressRulesLock.Unlock()
+	if r.ingressRules == nil {
+		r.ingressRules = make(map[string]string)
+	}
+
+	changed := false
+	if !exists || r.ingressRules[linkName] != rendered {
+		err := replaceIngressChain(ip, linkName, specs)
+		if err != nil {
+			return false, err
+		}
+		r.ingressRules[linkName] = rendered
+		changed = true
+	}
+
+	jump, err := ip.Exists("filter", "INPUT", "-i", linkName, "-j", chain)
+	if err != nil {
+		return changed, err
+	}
+	if !jump {
+		err := ip.Insert("filter", "INPUT", 1, "-i", linkName, "-j", chain)
+		if err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// replaceIngressChain renders the rules in a new chain, jumps to it from INPUT, and then
+// removes the previous chain of the link before renaming the new one in its place
+func replaceIngressChain(ip *iptables.IPTables, linkName string, specs [][]string) error {
+	chain := ingressChain(linkName)
+	newChain := newIngressChain(linkName)
+
+	// the new chain may be left over from an interrupted replacement
+	err := ip.DeleteIfExists("filter", "INPUT", "-i", linkName, "-j", newChain)
+	if err != nil {
+		return err
+	}
+	err = ip.ClearChain("filter", newChain)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		err := ip.Append("filter", newChain, spec...)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ip.Insert("filter", "INPUT", 1, "-i", linkName, "-j", newChain)
+	if err != nil {
+		return err
+	}
+	err = removeChain(ip, linkName, chain)
+	if err != nil {
+		return err
+	}
+	// the jump follows the renamed chain
+	return ip.RenameChain("filter", newChain, chain)
+}
+
+// removeIngressRules removes the chain of the link and the rule jumping to it
+func (r *NetworkInterfaceReconciler) removeIngressRules(ip *iptables.IPTables, linkName string) error {
+	err := removeIngressChain(ip, linkName)
+	if err != nil {
+		return err
+	}
+
+	r.ingressRulesLock.Lock()
+	defer r.ingressRulesLock.Unlock()
+	delete(r.ingressRules, linkName)
+	return nil
+}
+
+// removeIngressChain removes the chains of the link and the rules jumping to them, if they exist
+func removeIngressChain(ip *iptables.IPTables, linkName string) error {
+	if linkName == "" {
+		return nil
+	}
+
+	err := removeChain(ip, linkName, newIngressChain(linkName))
+	if err != nil {
+		return err
+	}
+	return removeChain(ip, linkName, ingressChain(linkName))
+}
+
+// removeChain removes the chain and the rule of the link jumping to it, if they exist
+func removeChain(ip *iptables.IPTables, linkName string, chain string) error {
+	err := ip.DeleteIfExists("filter", "INPUT", "-i", linkName, "-j", chain)
+	if err != nil {
+		return err
+	}
+
+	return ip.ClearAndDeleteChain("filter", chain)
+}
+
+func ingressChainExists(ip *iptables.IPTables, linkName string) (bool, error) {
+	return ip.ChainExists("filter", ingressChain(linkName))
+}
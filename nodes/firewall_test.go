@@ -0,0 +1,111 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var established = []string{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+
+func TestIngressRuleSpecs(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules vpcv1alpha1.PrivateNetworkIngressRules
+		dhcp  bool
+		want  [][]string
+	}{
+		{
+			name: "no rules",
+			want: [][]string{established},
+		},
+		{
+			name:  "default deny",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{DefaultDeny: true},
+			want:  [][]string{established, {"-j", "DROP"}},
+		},
+		{
+			name:  "dhcp replies are allowed",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{DefaultDeny: true},
+			dhcp:  true,
+			want: [][]string{
+				established,
+				{"-p", "udp", "--dport", "68", "-j", "ACCEPT"},
+				{"-j", "DROP"},
+			},
+		},
+		{
+			name: "rule without match allows everything",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{
+				Rules: []vpcv1alpha1.PrivateNetworkIngressRule{{}},
+			},
+			want: [][]string{established, {"-j", "ACCEPT"}},
+		},
+		{
+			name: "protocol is lowercased",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{
+				Rules: []vpcv1alpha1.PrivateNetworkIngressRule{{Protocol: vpcv1alpha1.IngressProtocolICMP}},
+			},
+			want: [][]string{established, {"-p", "icmp", "-j", "ACCEPT"}},
+		},
+		{
+			name: "sources and ports are expanded",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{
+				DefaultDeny: true,
+				Rules: []vpcv1alpha1.PrivateNetworkIngressRule{{
+					From:     []string{"10.0.0.0/24", "10.0.1.0/24"},
+					Protocol: vpcv1alpha1.IngressProtocolTCP,
+					Ports:    []int32{22, 443},
+				}},
+			},
+			want: [][]string{
+				established,
+				{"-s", "10.0.0.0/24", "-p", "tcp", "--dport", "22", "-j", "ACCEPT"},
+				{"-s", "10.0.0.0/24", "-p", "tcp", "--dport", "443", "-j", "ACCEPT"},
+				{"-s", "10.0.1.0/24", "-p", "tcp", "--dport", "22", "-j", "ACCEPT"},
+				{"-s", "10.0.1.0/24", "-p", "tcp", "--dport", "443", "-j", "ACCEPT"},
+				{"-j", "DROP"},
+			},
+		},
+		{
+			name: "rules keep their order",
+			rules: vpcv1alpha1.PrivateNetworkIngressRules{
+				Rules: []vpcv1alpha1.PrivateNetworkIngressRule{
+					{Protocol: vpcv1alpha1.IngressProtocolUDP, Ports: []int32{53}},
+					{From: []string{"192.168.0.0/16"}},
+				},
+			},
+			want: [][]string{
+				established,
+				{"-p", "udp", "--dport", "53", "-j", "ACCEPT"},
+				{"-s", "192.168.0.0/16", "-j", "ACCEPT"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ingressRuleSpecs(&test.rules, test.dhcp)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("specs = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -20,14 +20,21 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/go-logr/logr"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,6 +46,9 @@ import (
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/events"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/taints"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/metrics"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
 )
 
@@ -47,14 +57,31 @@ type NetworkInterfaceReconciler struct {
 	client.Client
 	Log         logr.Logger
 	Scheme      *runtime.Scheme
+	Recorder    record.EventRecorder
 	MetadataAPI *instance.MetadataAPI
 	NodeName    string
 	NICs        *nics.NICs
+	State       *State
+
+	leaseTimes     map[string]time.Time
+	leaseTimesLock sync.Mutex
+
+	// ingressRules are the last ingress rules rendered in the chain of each link
+	ingressRules     map[string]string
+	ingressRulesLock sync.Mutex
 }
 
+var (
+	// DHCPResyncDuration is the duration between two syncs of the links configured with DHCP
+	DHCPResyncDuration time.Duration = time.Minute
+)
+
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=get;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -85,29 +112,39 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 
 	if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
 		if controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
+			shared, err := r.isLinkShared(ctx, nic)
+			if err != nil {
+				log.Error(err, "unable to list networkInterfaces")
+				return ctrl.Result{}, err
+			}
 
-			if pnet.Spec.IPAM == nil {
-				err := r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Spec.Address)
+			// an orphaned link is left configured, and forgotten so that it's not torn down on cleanup
+			orphan := nic.Annotations[constants.OrphanAnnotation] == "true"
+			if orphan {
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonLinkOrphaned, "Left link %s configured", nic.Status.LinkName)
+			} else {
+				err = r.tearDownLink(nic, &pnet, shared)
 				if err != nil {
-					log.Error(err, "unable to configure link")
+					metrics.LinkTearDownFailures.WithLabelValues(pnet.Name).Inc()
+					log.Error(err, "unable to tear down link")
+					r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonLinkConfigurationFailed, "Unable to tear down link %s: %s", nic.Status.LinkName, err)
 					return ctrl.Result{}, err
 				}
-			} else {
-				switch pnet.Spec.IPAM.Type {
-				case vpcv1alpha1.IPAMTypeStatic:
-					err := r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Status.Address)
-					if err != nil {
-						log.Error(err, "unable to configure link")
-						return ctrl.Result{}, err
-					}
-				case vpcv1alpha1.IPAMTypeDHCP:
-					err := r.NICs.TearDownDHCPLink(nic.Status.MacAddress)
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonLinkTornDown, "Tore down link %s", nic.Status.LinkName)
+			}
+
+			if !shared {
+				if !orphan {
+					err = r.removeMasquerade(nic.Status.LinkName)
 					if err != nil {
-						log.Error(err, "unable to configure link")
+						log.Error(err, "unable to delete masquerade iptables rule")
 						return ctrl.Result{}, err
 					}
-				default:
-					return ctrl.Result{}, fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
+				}
+				err = r.State.RemoveLink(nic.Status.MacAddress)
+				if err != nil {
+					log.Error(err, "unable to save state")
+					return ctrl.Result{}, err
 				}
 			}
 
@@ -118,7 +155,27 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				log.Error(err, fmt.Sprintf("failed to patch networkInterface %s", nic.Name))
 				return ctrl.Result{}, err
 			}
+
+			err = r.syncNotReadyTaint(ctx)
+			if err != nil {
+				log.Error(err, "unable to sync node taint")
+				return ctrl.Result{}, err
+			}
+
+			err = r.syncNodeAddresses(ctx)
+			if err != nil {
+				log.Error(err, "unable to sync node addresses")
+				return ctrl.Result{}, err
+			}
 		}
+		return ctrl.Result{}, nil
+	}
+
+	// the controller marks the duplicate NetworkInterfaces of the node, the link is configured by the oldest one
+	condition := vpcv1alpha1.FindCondition(nic.Status.Conditions, vpcv1alpha1.NetworkInterfaceDegraded)
+	if condition != nil && condition.Status == corev1.ConditionTrue && condition.Reason == events.ReasonDuplicateNetworkInterface {
+		log.Info(fmt.Sprintf("networkInterface %s is a duplicate, ignoring it", nic.Name))
+		return ctrl.Result{}, nil
 	}
 
 	md, err := r.MetadataAPI.GetMetadata()
@@ -137,6 +194,8 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	if !found {
 		err := fmt.Errorf("nic not found on node")
 		log.Error(err, "unable to find nic")
+		r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonNICNotFound, "Private NIC with MAC %s not found in the metadata", nic.Status.MacAddress)
+		r.Recorder.Eventf(events.NodeRef(r.NodeName), corev1.EventTypeWarning, events.ReasonNICNotFound, "Private NIC with MAC %s of private network %s not found in the metadata", nic.Status.MacAddress, pnet.Name)
 		return ctrl.Result{}, err
 	}
 
@@ -146,6 +205,44 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, err
 	}
 
+	desiredLinkName, err := pnet.RenderInterfaceName()
+	if err != nil {
+		log.Error(err, "unable to render interface name")
+		return ctrl.Result{}, err
+	}
+
+	if desiredLinkName != "" && desiredLinkName != linkName {
+		ip, err := iptables.New()
+		if err != nil {
+			log.Error(err, "unable to create iptables helper")
+			return ctrl.Result{}, err
+		}
+		err = ip.DeleteIfExists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+		if err != nil {
+			log.Error(err, "unable to delete masquerade iptables rule")
+			return ctrl.Result{}, err
+		}
+		err = r.removeIngressRules(ip, linkName)
+		if err != nil {
+			log.Error(err, "unable to delete ingress iptables rules")
+			return ctrl.Result{}, err
+		}
+
+		err = r.NICs.SetLinkName(nic.Status.MacAddress, desiredLinkName)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to rename link %s to %s", linkName, desiredLinkName))
+			return ctrl.Result{}, err
+		}
+		log.Info(fmt.Sprintf("Successfully renamed link %s to %s", linkName, desiredLinkName))
+		linkName = desiredLinkName
+	}
+
+	err = r.State.SetLink(nic.Status.MacAddress, linkName)
+	if err != nil {
+		log.Error(err, "unable to save state")
+		return ctrl.Result{}, err
+	}
+
 	patch := client.MergeFrom(nic.DeepCopy())
 	nic.Status.LinkName = linkName
 	err = r.Client.Status().Patch(ctx, nic, patch)
@@ -154,10 +251,14 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, err
 	}
 
+	result := ctrl.Result{}
+
 	if pnet.Spec.IPAM == nil {
 		err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Spec.Address)
 		if err != nil {
+			metrics.LinkConfigureFailures.WithLabelValues(pnet.Name).Inc()
 			log.Error(err, "unable to configure link")
+			r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonLinkConfigurationFailed, "Unable to configure link %s: %s", linkName, err)
 			return ctrl.Result{}, err
 		}
 	} else {
@@ -165,22 +266,31 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		case vpcv1alpha1.IPAMTypeStatic:
 			err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Status.Address)
 			if err != nil {
+				metrics.LinkConfigureFailures.WithLabelValues(pnet.Name).Inc()
 				log.Error(err, "unable to configure link")
+				r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonLinkConfigurationFailed, "Unable to configure link %s: %s", linkName, err)
 				return ctrl.Result{}, err
 			}
 		case vpcv1alpha1.IPAMTypeDHCP:
 			ip, err := r.NICs.ConfigureDHCPLink(nic.Status.MacAddress)
 			if err != nil {
+				metrics.LinkConfigureFailures.WithLabelValues(pnet.Name).Inc()
 				log.Error(err, "unable to configure link")
+				r.Recorder.Eventf(nic, corev1.EventTypeWarning, events.ReasonLinkConfigurationFailed, "Unable to configure link %s: %s", linkName, err)
 				return ctrl.Result{}, err
 			}
-			patch := client.MergeFrom(nic.DeepCopy())
-			nic.Status.Address = ip
-			err = r.Client.Status().Patch(ctx, nic, patch)
-			if err != nil {
-				log.Error(err, "unable to patch status")
-				return ctrl.Result{}, err
+			r.observeDHCPLease(nic, &pnet)
+			if nic.Status.Address != ip {
+				patch := client.MergeFrom(nic.DeepCopy())
+				nic.Status.Address = ip
+				err = r.Client.Status().Patch(ctx, nic, patch)
+				if err != nil {
+					log.Error(err, "unable to patch status")
+					return ctrl.Result{}, err
+				}
+				r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonDHCPLease, "Got address %s from DHCP on link %s", ip, linkName)
 			}
+			result.RequeueAfter = DHCPResyncDuration
 		default:
 			return ctrl.Result{}, fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
 		}
@@ -204,6 +314,7 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			log.Error(err, "unable to append masquerade iptables rule")
 			return ctrl.Result{}, err
 		}
+		r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonMasqueradeEnabled, "Enabled masquerade on link %s", linkName)
 	}
 
 	if !pnet.Spec.Masquerade && isMasquerade {
@@ -212,6 +323,22 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			log.Error(err, "unable to delete masquerade iptables rule")
 			return ctrl.Result{}, err
 		}
+		r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonMasqueradeDisabled, "Disabled masquerade on link %s", linkName)
+	}
+
+	err = pnet.ValidateIngressRules()
+	if err != nil {
+		log.Error(err, "invalid ingressRules")
+		r.Recorder.Event(nic, corev1.EventTypeWarning, events.ReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, err
+	}
+	ingressChanged, err := r.syncIngressRules(ip, linkName, &pnet)
+	if err != nil {
+		log.Error(err, "unable to sync ingress iptables rules")
+		return ctrl.Result{}, err
+	}
+	if ingressChanged {
+		r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonIngressRulesSynced, "Synced ingress rules on link %s", linkName)
 	}
 
 	routes := []nics.Route{}
@@ -228,13 +355,384 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		})
 	}
 
-	err = r.NICs.SyncRoutes(nic.Status.MacAddress, routes)
+	podRoutes, err := peerPodRoutes(ctx, r.Client, nic, &pnet)
+	if err != nil {
+		log.Error(err, "unable to get pod cidrs of the peers")
+		return ctrl.Result{}, err
+	}
+	routes = append(routes, podRoutes...)
+
+	added, deleted, err := r.NICs.SyncRoutes(nic.Status.MacAddress, routes)
+	metrics.RouteChanges.WithLabelValues(pnet.Name, "add").Add(float64(added))
+	metrics.RouteChanges.WithLabelValues(pnet.Name, "delete").Add(float64(deleted))
 	if err != nil {
 		log.Error(err, "unable to sync routes")
 		return ctrl.Result{}, err
 	}
+	if added+deleted > 0 {
+		r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonRoutesSynced, "Synced routes on link %s: %d added, %d deleted", linkName, added, deleted)
+	}
+
+	if nic.Status.Phase != vpcv1alpha1.NetworkInterfacePhaseReady {
+		patch := client.MergeFrom(nic.DeepCopy())
+		nic.Status.Phase = vpcv1alpha1.NetworkInterfacePhaseReady
+		err = r.Client.Status().Patch(ctx, nic, patch)
+		if err != nil {
+			log.Error(err, "unable to patch status")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(nic, corev1.EventTypeNormal, events.ReasonLinkConfigured, "Configured link %s", linkName)
+	}
+
+	err = r.syncNotReadyTaint(ctx)
+	if err != nil {
+		log.Error(err, "unable to sync node taint")
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	err = r.syncNodeAddresses(ctx)
+	if err != nil {
+		log.Error(err, "unable to sync node addresses")
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// syncNotReadyTaint removes the NotReady taint from the node once the NetworkInterfaces
+// of all the PrivateNetworks required for scheduling and selecting the node are Ready
+func (r *NetworkInterfaceReconciler) syncNotReadyTaint(ctx context.Context) error {
+	node := &corev1.Node{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.NodeName}, node)
+	if err != nil {
+		return err
+	}
+
+	if !taints.HasNotReady(node) {
+		return nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	readyPNs := make(map[string]struct{})
+	for _, nic := range nicsList.Items {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() && nic.Status.Phase == vpcv1alpha1.NetworkInterfacePhaseReady {
+			readyPNs[nic.Labels[constants.PrivateNetworkLabel]] = struct{}{}
+		}
+	}
+
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err = r.Client.List(ctx, pnsList)
+	if err != nil {
+		return err
+	}
+
+	for _, pnet := range pnsList.Items {
+		if !pnet.Spec.RequiredForScheduling || !pnet.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if _, ok := readyPNs[pnet.Name]; ok {
+			continue
+		}
+		nodeSelector, err := pnet.NodeLabelSelector()
+		if err != nil {
+			r.Log.Error(err, fmt.Sprintf("invalid nodeSelector on privateNetwork %s", pnet.Name))
+			continue
+		}
+		if nodeSelector.Matches(labels.Set(node.Labels)) {
+			return nil
+		}
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	taints.RemoveNotReady(node)
+	err = r.Client.Patch(ctx, node, patch)
+	if err != nil {
+		return err
+	}
+	r.Log.Info(fmt.Sprintf("Successfully removed taint %s from node %s", constants.NotReadyTaintKey, r.NodeName))
+	return nil
+}
+
+// advertisedNodeAddresses returns the addresses of the node with the desired addresses as the first InternalIPs,
+// without the previously advertised ones that are not desired anymore. The addresses only need to be patched
+// when a desired address is missing or a previous one is left: the kubelet and the cloud controller manager
+// rewrite the whole list, so the order is not enforced to avoid patching it back on every status update.
+func advertisedNodeAddresses(current []corev1.NodeAddress, desired []string, previous []string) ([]corev1.NodeAddress, bool) {
+	present := make(map[string]struct{})
+	for _, address := range current {
+		if address.Type == corev1.NodeInternalIP {
+			present[address.Address] = struct{}{}
+		}
+	}
+	wanted := make(map[string]struct{})
+	changed := false
+	for _, address := range desired {
+		wanted[address] = struct{}{}
+		if _, ok := present[address]; !ok {
+			changed = true
+		}
+	}
+	for _, address := range previous {
+		if _, ok := wanted[address]; ok {
+			continue
+		}
+		if _, ok := present[address]; ok {
+			changed = true
+		}
+	}
+	if !changed {
+		return current, false
+	}
+
+	managed := make(map[string]struct{})
+	for _, address := range append(previous, desired...) {
+		managed[address] = struct{}{}
+	}
+	addresses := []corev1.NodeAddress{}
+	for _, address := range desired {
+		addresses = append(addresses, corev1.NodeAddress{
+			Type:    corev1.NodeInternalIP,
+			Address: address,
+		})
+	}
+	for _, address := range current {
+		if _, ok := managed[address.Address]; ok && address.Type == corev1.NodeInternalIP {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, true
+}
+
+// syncNodeAddresses adds the addresses of the NetworkInterfaces advertised as node address as the first
+// InternalIPs of the node, and removes the ones previously added that are not advertised anymore
+// The kubelet and the cloud controller manager overwrite the addresses of the node, so they are also
+// added back by the NodeAddressReconciler on every update of the node
+func (r *NetworkInterfaceReconciler) syncNodeAddresses(ctx context.Context) error {
+	node := &corev1.Node{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.NodeName}, node)
+	if err != nil {
+		return err
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	desired := []string{}
+	for _, nic := range nicsList.Items {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: nic.Labels[constants.PrivateNetworkLabel]}, &pnet)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !pnet.Spec.AdvertiseAsNodeAddress {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(networkInterfaceAddress(&nic, &pnet))
+		if err != nil {
+			continue
+		}
+		desired = append(desired, ip.String())
+	}
+
+	previous := []string{}
+	if node.Annotations[constants.AdvertisedAddressesAnnotation] != "" {
+		previous = strings.Split(node.Annotations[constants.AdvertisedAddressesAnnotation], ",")
+	}
+
+	addresses, changed := advertisedNodeAddresses(node.Status.Addresses, desired, previous)
+	if changed {
+		patch := client.MergeFrom(node.DeepCopy())
+		node.Status.Addresses = addresses
+		err = r.Client.Status().Patch(ctx, node, patch)
+		if err != nil {
+			return err
+		}
+		r.Log.Info(fmt.Sprintf("Successfully advertised addresses %v on node %s", desired, r.NodeName))
+	}
+
+	if strings.Join(desired, ",") != node.Annotations[constants.AdvertisedAddressesAnnotation] {
+		patch := client.MergeFrom(node.DeepCopy())
+		if len(desired) == 0 {
+			delete(node.Annotations, constants.AdvertisedAddressesAnnotation)
+		} else {
+			if node.Annotations == nil {
+				node.Annotations = make(map[string]string)
+			}
+			node.Annotations[constants.AdvertisedAddressesAnnotation] = strings.Join(desired, ",")
+		}
+		err = r.Client.Patch(ctx, node, patch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// peerPodRoutes returns the routes to the pod CIDRs of the other nodes of the PrivateNetwork,
+// through their address in the PrivateNetwork
+func peerPodRoutes(ctx context.Context, c client.Reader, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) ([]nics.Route, error) {
+	if pnet.Spec.IPAM == nil || pnet.Spec.IPAM.Static == nil || pnet.Spec.IPAM.Static.PodCIDRSize == 0 {
+		return nil, nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := c.List(ctx, nicsList, client.MatchingLabels{
+		constants.PrivateNetworkLabel: pnet.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []nics.Route{}
+	for _, peer := range nicsList.Items {
+		if peer.Spec.NodeName == nic.Spec.NodeName || !peer.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if peer.Status.PodCIDR == "" || peer.Status.Address == "" {
+			continue
+		}
+		to, err := netlink.ParseIPNet(peer.Status.PodCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod cidr %s of networkInterface %s: %w", peer.Status.PodCIDR, peer.Name, err)
+		}
+		via, _, err := net.ParseCIDR(peer.Status.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s of networkInterface %s: %w", peer.Status.Address, peer.Name, err)
+		}
+		routes = append(routes, nics.Route{
+			To:  to,
+			Via: via,
+		})
+	}
+	return routes, nil
+}
+
+// networkInterfaceAddress returns the address of the NetworkInterface, in CIDR notation
+func networkInterfaceAddress(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) string {
+	if pnet.Spec.IPAM == nil {
+		// deprecated
+		return nic.Spec.Address
+	}
+	return nic.Status.Address
+}
+
+// observeDHCPLease counts the DHCP lease renewals of the link, based on the modification time of the lease
+func (r *NetworkInterfaceReconciler) observeDHCPLease(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) {
+	leaseTime, err := r.NICs.GetDHCPLeaseTime(nic.Status.MacAddress)
+	if err != nil {
+		r.Log.Error(err, fmt.Sprintf("unable to get dhcp lease of networkInterface %s", nic.Name))
+		return
+	}
+
+	r.leaseTimesLock.Lock()
+	defer r.leaseTimesLock.Unlock()
+
+	if r.leaseTimes == nil {
+		r.leaseTimes = make(map[string]time.Time)
+	}
+	previous, ok := r.leaseTimes[nic.Status.MacAddress]
+	if ok && leaseTime.After(previous) {
+		metrics.DHCPRenewals.WithLabelValues(pnet.Name).Inc()
+	}
+	r.leaseTimes[nic.Status.MacAddress] = leaseTime
+}
+
+// ReadyCheck returns an error if a NetworkInterface of the node is not Ready
+func (r *NetworkInterfaceReconciler) ReadyCheck(_ *http.Request) error {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(context.Background(), nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, nic := range nicsList.Items {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() && nic.Status.Phase != vpcv1alpha1.NetworkInterfacePhaseReady {
+			return fmt.Errorf("networkInterface %s is not ready", nic.Name)
+		}
+	}
+	return nil
+}
+
+// removeMasquerade deletes the masquerade iptables rule of the link
+func (r *NetworkInterfaceReconciler) removeMasquerade(linkName string) error {
+	if linkName == "" {
+		return nil
+	}
+	ip, err := iptables.New()
+	if err != nil {
+		return err
+	}
+	err = ip.DeleteIfExists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	if err != nil {
+		return err
+	}
+	return r.removeIngressRules(ip, linkName)
+}
+
+// isLinkShared returns whether another NetworkInterface of the node uses the same link
+func (r *NetworkInterfaceReconciler) isLinkShared(ctx context.Context, nic *vpcv1alpha1.NetworkInterface) (bool, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, n := range nicsList.Items {
+		if n.Name != nic.Name && n.Status.MacAddress == nic.Status.MacAddress && n.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// tearDownLink removes the configuration of the NetworkInterface from its link.
+// When the link is shared with another NetworkInterface, only the static address is removed.
+func (r *NetworkInterfaceReconciler) tearDownLink(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, shared bool) error {
+	if pnet.Spec.IPAM == nil {
+		if shared {
+			return r.NICs.RemoveAddress(nic.Status.MacAddress, nic.Spec.Address)
+		}
+		return r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Spec.Address)
+	}
+
+	switch pnet.Spec.IPAM.Type {
+	case vpcv1alpha1.IPAMTypeStatic:
+		if shared {
+			return r.NICs.RemoveAddress(nic.Status.MacAddress, nic.Status.Address)
+		}
+		return r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Status.Address)
+	case vpcv1alpha1.IPAMTypeDHCP:
+		if shared {
+			return nil
+		}
+		return r.NICs.TearDownDHCPLink(nic.Status.MacAddress)
+	default:
+		return fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
+	}
 }
 
 func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -265,5 +763,39 @@ func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				}
 			},
 		}).
+		Watches(&source.Kind{
+			Type: &vpcv1alpha1.NetworkInterface{},
+		}, &handler.EnqueueRequestsFromMapFunc{
+			// the routes to the pod CIDRs of the peers depend on their NetworkInterfaces
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				if o.Meta.GetLabels()[constants.NodeLabel] == r.NodeName {
+					return nil
+				}
+				pnName := o.Meta.GetLabels()[constants.PrivateNetworkLabel]
+				if pnName == "" {
+					return nil
+				}
+				nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+				err := r.Client.List(context.Background(), nicsList,
+					client.MatchingLabels{
+						constants.NodeLabel:           r.NodeName,
+						constants.PrivateNetworkLabel: pnName,
+					},
+				)
+				if err != nil {
+					r.Log.Error(err, "unable to sync nics on peer networkInterface update")
+					return nil
+				}
+				requests := []reconcile.Request{}
+				for _, nic := range nicsList.Items {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Name: nic.Name,
+						},
+					})
+				}
+				return requests
+			}),
+		}).
 		Complete(r)
 }
@@ -0,0 +1,116 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func internalIP(address string) corev1.NodeAddress {
+	return corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: address}
+}
+
+var hostname = corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node-1"}
+
+func TestAdvertisedNodeAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  []corev1.NodeAddress
+		desired  []string
+		previous []string
+		want     []corev1.NodeAddress
+		changed  bool
+	}{
+		{
+			name:    "missing address is added first",
+			current: []corev1.NodeAddress{internalIP("10.0.0.1"), hostname},
+			desired: []string{"192.168.0.2"},
+			want:    []corev1.NodeAddress{internalIP("192.168.0.2"), internalIP("10.0.0.1"), hostname},
+			changed: true,
+		},
+		{
+			name:     "present address is not reordered",
+			current:  []corev1.NodeAddress{internalIP("10.0.0.1"), internalIP("192.168.0.2"), hostname},
+			desired:  []string{"192.168.0.2"},
+			previous: []string{"192.168.0.2"},
+			want:     []corev1.NodeAddress{internalIP("10.0.0.1"), internalIP("192.168.0.2"), hostname},
+			changed:  false,
+		},
+		{
+			name:     "previous address is removed",
+			current:  []corev1.NodeAddress{internalIP("192.168.0.2"), internalIP("10.0.0.1"), hostname},
+			previous: []string{"192.168.0.2"},
+			want:     []corev1.NodeAddress{internalIP("10.0.0.1"), hostname},
+			changed:  true,
+		},
+		{
+			name:     "removed previous address is left alone",
+			current:  []corev1.NodeAddress{internalIP("10.0.0.1"), hostname},
+			previous: []string{"192.168.0.2"},
+			want:     []corev1.NodeAddress{internalIP("10.0.0.1"), hostname},
+			changed:  false,
+		},
+		{
+			name:     "address of another type is not advertised",
+			current:  []corev1.NodeAddress{{Type: corev1.NodeExternalIP, Address: "192.168.0.2"}},
+			desired:  []string{"192.168.0.2"},
+			previous: []string{"192.168.0.2"},
+			want:     []corev1.NodeAddress{internalIP("192.168.0.2"), {Type: corev1.NodeExternalIP, Address: "192.168.0.2"}},
+			changed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed := advertisedNodeAddresses(test.current, test.desired, test.previous)
+			if changed != test.changed {
+				t.Errorf("changed = %t, want %t", changed, test.changed)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("addresses = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestAdvertisedNodeAddressesConverge checks that the addresses are patched at most once
+// when the kubelet rewrites them with the address of the private network, e.g. with --node-ip
+func TestAdvertisedNodeAddressesConverge(t *testing.T) {
+	desired := []string{"192.168.0.2"}
+	current := []corev1.NodeAddress{internalIP("10.0.0.1"), hostname}
+
+	current, changed := advertisedNodeAddresses(current, desired, nil)
+	if !changed {
+		t.Fatalf("the missing address was not added")
+	}
+	_, changed = advertisedNodeAddresses(current, desired, desired)
+	if changed {
+		t.Fatalf("the addresses were patched again: %v", current)
+	}
+
+	// the kubelet reports the addresses in its own order
+	kubelet := []corev1.NodeAddress{internalIP("10.0.0.1"), internalIP("192.168.0.2"), hostname}
+	for i := 0; i < 3; i++ {
+		kubelet, changed = advertisedNodeAddresses(kubelet, desired, desired)
+		if changed {
+			t.Fatalf("the addresses written by the kubelet were patched: %v", kubelet)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// NodeAddressReconciler adds back the addresses advertised by the NetworkInterfaces of the node when
+// the kubelet or the cloud controller manager overwrites them, without reconfiguring the links
+type NodeAddressReconciler struct {
+	*NetworkInterfaceReconciler
+}
+
+func (r *NodeAddressReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	err := r.syncNodeAddresses(ctx)
+	if err != nil {
+		r.Log.Error(err, "unable to sync node addresses")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *NodeAddressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return e.Meta.GetName() == r.NodeName
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				if e.MetaNew.GetName() != r.NodeName {
+					return false
+				}
+				oldNode, ok := e.ObjectOld.(*corev1.Node)
+				if !ok {
+					return false
+				}
+				newNode, ok := e.ObjectNew.(*corev1.Node)
+				if !ok {
+					return false
+				}
+				return !equality.Semantic.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
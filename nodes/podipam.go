@@ -0,0 +1,202 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/podipam"
+)
+
+// PodIPAMServer serves the addresses of the pods attached to the PrivateNetworks on a unix socket,
+// for the CNI IPAM plugin
+type PodIPAMServer struct {
+	client.Client
+	Log        logr.Logger
+	NodeName   string
+	SocketPath string
+	Store      *podipam.Store
+}
+
+// Start serves the pod IPAM until the stop channel is closed
+func (s *PodIPAMServer) Start(stop <-chan struct{}) error {
+	err := os.MkdirAll(filepath.Dir(s.SocketPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(s.SocketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", s.SocketPath)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(podipam.AllocatePath, s.handleAllocate)
+	mux.HandleFunc(podipam.ReleasePath, s.handleRelease)
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		<-stop
+		server.Close()
+	}()
+
+	err = server.Serve(listener)
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection makes the pod IPAM run on every node
+func (s *PodIPAMServer) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *PodIPAMServer) handleAllocate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePodIPAMRequest(w, r)
+	if !ok {
+		return
+	}
+
+	allocation, err := s.allocate(r.Context(), req)
+	if err != nil {
+		s.Log.Error(err, fmt.Sprintf("unable to allocate address for container %s in private network %s", req.ContainerID, req.PrivateNetwork))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.Log.Info(fmt.Sprintf("Successfully allocated address %s for container %s", allocation.Address, req.ContainerID))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(allocation)
+}
+
+func (s *PodIPAMServer) handleRelease(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePodIPAMRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.Store.Release(req)
+	if err != nil {
+		s.Log.Error(err, fmt.Sprintf("unable to release address of container %s in private network %s", req.ContainerID, req.PrivateNetwork))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func decodePodIPAMRequest(w http.ResponseWriter, r *http.Request) (podipam.Request, bool) {
+	req := podipam.Request{}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	if req.PrivateNetwork == "" || req.ContainerID == "" || req.IfName == "" {
+		http.Error(w, "privateNetwork, containerID and ifName are required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// allocate gives the container an address from the pod CIDR of the NetworkInterface of the node
+func (s *PodIPAMServer) allocate(ctx context.Context, req podipam.Request) (*podipam.Allocation, error) {
+	pnet := vpcv1alpha1.PrivateNetwork{}
+	err := s.Client.Get(ctx, types.NamespacedName{Name: req.PrivateNetwork}, &pnet)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get private network: %w", err)
+	}
+	if pnet.Spec.IPAM == nil || pnet.Spec.IPAM.Static == nil {
+		return nil, fmt.Errorf("private network %s has no static IPAM", pnet.Name)
+	}
+	_, cidr, err := net.ParseCIDR(pnet.Spec.IPAM.Static.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = s.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel:           s.NodeName,
+		constants.PrivateNetworkLabel: pnet.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list networkInterfaces: %w", err)
+	}
+
+	podCIDR := ""
+	for _, nic := range nicsList.Items {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() && nic.Status.PodCIDR != "" {
+			podCIDR = nic.Status.PodCIDR
+			break
+		}
+	}
+	if podCIDR == "" {
+		return nil, fmt.Errorf("no pod cidr allocated to node %s in private network %s yet", s.NodeName, pnet.Name)
+	}
+	_, podNet, err := net.ParseCIDR(podCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	if pnet.Spec.PodAttachment == nil {
+		// the pods are routed through the node, in the pod cidr
+		ip, err := s.Store.Allocate(req, podNet, podNet)
+		if err != nil {
+			return nil, err
+		}
+		return &podipam.Allocation{
+			Address: (&net.IPNet{IP: ip, Mask: podNet.Mask}).String(),
+		}, nil
+	}
+
+	// the pods are on the same network as the nodes
+	ip, err := s.Store.Allocate(req, podNet, cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := cidr.Mask.Size()
+	allocation := &podipam.Allocation{
+		Address: fmt.Sprintf("%s/%d", ip, ones),
+	}
+	for _, route := range pnet.Spec.Routes {
+		allocation.Routes = append(allocation.Routes, podipam.Route{
+			Dst: route.To,
+			Gw:  route.Via,
+		})
+	}
+	return allocation, nil
+}
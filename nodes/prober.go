@@ -0,0 +1,242 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/metrics"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/probe"
+)
+
+var (
+	// ProberTick is the duration between two checks of the health checks to run
+	ProberTick time.Duration = time.Second * 5
+)
+
+// Prober periodically probes the health check targets of the PrivateNetworks through the links of the node
+type Prober struct {
+	client.Client
+	Log      logr.Logger
+	NodeName string
+
+	// lastProbes are the times of the last probe of each NetworkInterface
+	lastProbes map[string]time.Time
+}
+
+// probeResult is the result of the probe of a target
+type probeResult struct {
+	target  string
+	latency time.Duration
+	err     error
+}
+
+// Start runs the prober until the stop channel is closed
+func (p *Prober) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(ProberTick)
+	defer ticker.Stop()
+
+	p.lastProbes = make(map[string]time.Time)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			err := p.ProbeAll(context.Background())
+			if err != nil {
+				p.Log.Error(err, "unable to probe private networks")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes the prober run on every node
+func (p *Prober) NeedLeaderElection() bool {
+	return false
+}
+
+// ProbeAll probes the NetworkInterfaces of the node whose health check is due
+func (p *Prober) ProbeAll(ctx context.Context) error {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := p.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: p.NodeName,
+	})
+	if err != nil {
+		return fmt.Errorf("could not list networkInterfaces: %w", err)
+	}
+
+	seen := make(map[string]time.Time)
+	for _, nic := range nicsList.Items {
+		if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() || nic.Status.Phase != vpcv1alpha1.NetworkInterfacePhaseReady {
+			continue
+		}
+
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := p.Client.Get(ctx, types.NamespacedName{Name: nic.Labels[constants.PrivateNetworkLabel]}, &pnet)
+		if err != nil {
+			p.Log.Error(err, fmt.Sprintf("unable to get private network of networkInterface %s", nic.Name))
+			continue
+		}
+		if pnet.Spec.HealthCheck == nil {
+			continue
+		}
+
+		last := p.lastProbes[nic.Name]
+		seen[nic.Name] = last
+		if time.Since(last) < time.Duration(pnet.Spec.HealthCheck.PeriodSeconds)*time.Second {
+			continue
+		}
+		seen[nic.Name] = time.Now()
+
+		err = p.probeNetworkInterface(ctx, &nic, &pnet)
+		if err != nil {
+			p.Log.Error(err, fmt.Sprintf("unable to probe networkInterface %s", nic.Name))
+		}
+	}
+	p.lastProbes = seen
+
+	return nil
+}
+
+// probeNetworkInterface probes the targets of the NetworkInterface and reports the result in its Reachable condition
+func (p *Prober) probeNetworkInterface(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	src, _, err := net.ParseCIDR(networkInterfaceAddress(nic, pnet))
+	if err != nil {
+		return fmt.Errorf("invalid address of networkInterface %s: %w", nic.Name, err)
+	}
+
+	targets, err := p.healthCheckTargets(ctx, pnet)
+	if err != nil {
+		return err
+	}
+
+	healthCheck := pnet.Spec.HealthCheck
+	timeout := time.Duration(healthCheck.TimeoutSeconds) * time.Second
+
+	results := make([]probeResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target net.IP) {
+			defer wg.Done()
+			result := probeResult{target: target.String()}
+			switch healthCheck.Protocol {
+			case vpcv1alpha1.HealthCheckProtocolTCP:
+				result.latency, result.err = probe.TCP(src, target, healthCheck.Port, timeout)
+			default:
+				result.latency, result.err = probe.ICMP(src, target, timeout)
+			}
+			results[i] = result
+		}(i, target)
+	}
+	wg.Wait()
+
+	unreachable := []string{}
+	var maxLatency time.Duration
+	for _, result := range results {
+		if result.err != nil {
+			metrics.ProbeReachable.WithLabelValues(pnet.Name, result.target).Set(0)
+			unreachable = append(unreachable, result.target)
+			p.Log.V(1).Info(fmt.Sprintf("target %s unreachable from %s: %s", result.target, src, result.err))
+			continue
+		}
+		metrics.ProbeReachable.WithLabelValues(pnet.Name, result.target).Set(1)
+		metrics.ProbeLatency.WithLabelValues(pnet.Name, result.target).Set(result.latency.Seconds())
+		if result.latency > maxLatency {
+			maxLatency = result.latency
+		}
+	}
+
+	condition := vpcv1alpha1.Condition{
+		Type:    vpcv1alpha1.NetworkInterfaceReachable,
+		Status:  corev1.ConditionTrue,
+		Reason:  "ProbeSucceeded",
+		Message: fmt.Sprintf("%d targets reachable, max latency %s", len(results), maxLatency),
+	}
+	if len(unreachable) != 0 {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "ProbeFailed"
+		condition.Message = fmt.Sprintf("%d/%d targets unreachable: %s", len(unreachable), len(results), strings.Join(unreachable, ", "))
+	}
+
+	patch := client.MergeFrom(nic.DeepCopy())
+	vpcv1alpha1.SetCondition(&nic.Status.Conditions, condition)
+	return p.Client.Status().Patch(ctx, nic, patch)
+}
+
+// healthCheckTargets returns the addresses to probe for the PrivateNetwork
+func (p *Prober) healthCheckTargets(ctx context.Context, pnet *vpcv1alpha1.PrivateNetwork) ([]net.IP, error) {
+	targets := make(map[string]net.IP)
+
+	if pnet.Spec.HealthCheck.Gateways {
+		for _, route := range pnet.Spec.Routes {
+			if via := net.ParseIP(route.Via); via != nil {
+				targets[via.String()] = via
+			}
+		}
+	}
+
+	for _, address := range pnet.Spec.HealthCheck.IPs {
+		if ip := net.ParseIP(address); ip != nil {
+			targets[ip.String()] = ip
+		}
+	}
+
+	if pnet.Spec.HealthCheck.Peers {
+		nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+		err := p.Client.List(ctx, nicsList, client.MatchingLabels{
+			constants.PrivateNetworkLabel: pnet.Name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list networkInterfaces: %w", err)
+		}
+		for _, peer := range nicsList.Items {
+			if peer.Spec.NodeName == p.NodeName || peer.Status.Phase != vpcv1alpha1.NetworkInterfacePhaseReady {
+				continue
+			}
+			if ip, _, err := net.ParseCIDR(networkInterfaceAddress(&peer, pnet)); err == nil {
+				targets[ip.String()] = ip
+			}
+		}
+	}
+
+	addresses := make([]string, 0, len(targets))
+	for address := range targets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	ips := make([]net.IP, 0, len(addresses))
+	for _, address := range addresses {
+		ips = append(ips, targets[address])
+	}
+	return ips, nil
+}
@@ -0,0 +1,170 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// ServiceReconciler announces on the private links the addresses of the LoadBalancer Services
+// the node is elected for
+type ServiceReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	NodeName string
+	NICs     *nics.NICs
+	State    *State
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+
+// Reconcile syncs all the announced addresses of the node, whatever the request
+func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log
+
+	desired, err := r.desiredAddresses(ctx)
+	if err != nil {
+		log.Error(err, "unable to get the addresses to announce")
+		return ctrl.Result{}, err
+	}
+
+	var lastErr error
+	for ip, mac := range r.State.GetAddresses() {
+		if desiredMAC, ok := desired[ip]; ok && desiredMAC == mac {
+			continue
+		}
+		err := r.NICs.RemoveAddress(mac, ip+"/32")
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to remove address %s", ip))
+			lastErr = err
+			continue
+		}
+		err = r.State.RemoveAddress(ip)
+		if err != nil {
+			log.Error(err, "unable to save state")
+			lastErr = err
+			continue
+		}
+		log.Info(fmt.Sprintf("Successfully stopped announcing address %s", ip))
+	}
+
+	managed := r.State.GetAddresses()
+	for ip, mac := range desired {
+		if _, ok := managed[ip]; ok {
+			continue
+		}
+		err := r.NICs.AddAddress(mac, ip+"/32")
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to add address %s", ip))
+			lastErr = err
+			continue
+		}
+		err = r.State.SetAddress(ip, mac)
+		if err != nil {
+			log.Error(err, "unable to save state")
+			lastErr = err
+			continue
+		}
+		// the address is announced even if the gratuitous ARP fails, the neighbours will resolve it eventually
+		err = r.NICs.Announce(mac, ip)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to send gratuitous ARP for address %s", ip))
+		}
+		log.Info(fmt.Sprintf("Successfully announced address %s", ip))
+	}
+
+	return ctrl.Result{}, lastErr
+}
+
+// desiredAddresses returns the addresses of the Services the node is elected for, with the mac address
+// of the link they are announced on
+func (r *ServiceReconciler) desiredAddresses(ctx context.Context) (map[string]string, error) {
+	svcsList := &corev1.ServiceList{}
+	err := r.Client.List(ctx, svcsList)
+	if err != nil {
+		return nil, err
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	macs := make(map[string]string)
+	for _, nic := range nicsList.Items {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() && nic.Status.Phase == vpcv1alpha1.NetworkInterfacePhaseReady {
+			macs[nic.Labels[constants.PrivateNetworkLabel]] = nic.Status.MacAddress
+		}
+	}
+
+	desired := make(map[string]string)
+	for _, svc := range svcsList.Items {
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || !svc.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if svc.Annotations[constants.AnnouncingNodeAnnotation] != r.NodeName {
+			continue
+		}
+		address := svc.Annotations[constants.AllocatedAddressAnnotation]
+		mac, ok := macs[svc.Annotations[constants.PrivateNetworkServiceAnnotation]]
+		if address == "" || !ok {
+			continue
+		}
+		desired[address] = mac
+	}
+	return desired, nil
+}
+
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Service{}).
+		Watches(&source.Kind{
+			Type: &vpcv1alpha1.NetworkInterface{},
+		}, &handler.EnqueueRequestsFromMapFunc{
+			// the addresses are announced once the link is Ready, and removed with it
+			ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+				if o.Meta.GetLabels()[constants.NodeLabel] != r.NodeName {
+					return nil
+				}
+				return []reconcile.Request{{
+					NamespacedName: types.NamespacedName{
+						Name: o.Meta.GetName(),
+					},
+				}}
+			}),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,216 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/go-logr/logr"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// State records the links configured by the node daemon on the host, so that they can be
+// torn down after a crash of the daemon, or when it is uninstalled
+type State struct {
+	path string
+	lock sync.Mutex
+
+	// Links are the names of the configured links, by mac address
+	Links map[string]string `json:"links"`
+
+	// Addresses are the mac addresses of the links the Service addresses are announced on, by address
+	Addresses map[string]string `json:"addresses,omitempty"`
+}
+
+// LoadState reads the state from the given file, an empty state is returned if the file does not exist
+func LoadState(path string) (*State, error) {
+	state := &State{
+		path:      path,
+		Links:     make(map[string]string),
+		Addresses: make(map[string]string),
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode state file %s: %w", path, err)
+	}
+	if state.Links == nil {
+		state.Links = make(map[string]string)
+	}
+	if state.Addresses == nil {
+		state.Addresses = make(map[string]string)
+	}
+	return state, nil
+}
+
+// GetLinks returns a copy of the configured links
+func (s *State) GetLinks() map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	links := make(map[string]string, len(s.Links))
+	for mac, name := range s.Links {
+		links[mac] = name
+	}
+	return links
+}
+
+// SetLink records the link with the given mac address as configured
+func (s *State) SetLink(mac string, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if current, ok := s.Links[mac]; ok && current == name {
+		return nil
+	}
+	s.Links[mac] = name
+	return s.save()
+}
+
+// RemoveLink removes the link with the given mac address from the configured links
+func (s *State) RemoveLink(mac string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.Links[mac]; !ok {
+		return nil
+	}
+	delete(s.Links, mac)
+	return s.save()
+}
+
+// GetAddresses returns a copy of the announced Service addresses
+func (s *State) GetAddresses() map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	addresses := make(map[string]string, len(s.Addresses))
+	for ip, mac := range s.Addresses {
+		addresses[ip] = mac
+	}
+	return addresses
+}
+
+// SetAddress records the Service address as announced on the link with the given mac address
+func (s *State) SetAddress(ip string, mac string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if current, ok := s.Addresses[ip]; ok && current == mac {
+		return nil
+	}
+	s.Addresses[ip] = mac
+	return s.save()
+}
+
+// RemoveAddress removes the Service address from the announced addresses
+func (s *State) RemoveAddress(ip string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.Addresses[ip]; !ok {
+		return nil
+	}
+	delete(s.Addresses, ip)
+	return s.save()
+}
+
+// save writes the state to a temporary file before renaming it, to never leave a partial state file
+func (s *State) save() error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// TearDownLinks tears down the links of the state, except the ones whose mac address is in keep,
+// and removes them from the state
+func TearDownLinks(log logr.Logger, state *State, nics *nics.NICs, keep map[string]struct{}) error {
+	ip, err := iptables.New()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for mac, name := range state.GetLinks() {
+		if _, ok := keep[mac]; ok {
+			continue
+		}
+
+		linkName, err := nics.GetLinkName(mac)
+		if err != nil {
+			linkName = name
+		}
+
+		err = nics.TearDownLink(mac)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to tear down link %s", linkName))
+			lastErr = err
+			continue
+		}
+
+		err = ip.DeleteIfExists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to delete masquerade iptables rule of link %s", linkName))
+			lastErr = err
+			continue
+		}
+
+		err = removeIngressChain(ip, linkName)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to delete ingress iptables rules of link %s", linkName))
+			lastErr = err
+			continue
+		}
+
+		err = state.RemoveLink(mac)
+		if err != nil {
+			log.Error(err, "unable to save state")
+			lastErr = err
+			continue
+		}
+		log.Info(fmt.Sprintf("Successfully tore down link %s", linkName))
+	}
+	return lastErr
+}
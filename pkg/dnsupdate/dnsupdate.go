@@ -0,0 +1,288 @@
+package dnsupdate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// opCodeUpdate is the opcode of the dynamic updates
+	opCodeUpdate dnsmessage.OpCode = 5
+	// typeTSIG is the type of the TSIG records
+	typeTSIG dnsmessage.Type = 250
+
+	// tsigAlgorithm is the only algorithm used to sign the updates
+	tsigAlgorithm = "hmac-sha256."
+	// tsigFudge is the allowed clock skew with the server, in seconds
+	tsigFudge = 300
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Client sends RFC 2136 dynamic updates to a DNS server, over TCP
+type Client struct {
+	// Server is the address of the DNS server, e.g. 192.168.0.2:53
+	Server string
+	// Zone is the zone the records are updated in
+	Zone string
+	// TTL is the TTL of the added records
+	TTL uint32
+
+	// KeyName is the name of the TSIG key, the updates are not signed if empty
+	KeyName string
+	// Secret is the HMAC-SHA256 secret of the TSIG key
+	Secret []byte
+
+	// Timeout is the timeout of an update, defaults to 10 seconds
+	Timeout time.Duration
+}
+
+// Replace replaces the A and AAAA records of the name with the given addresses,
+// the records are removed if no address is given
+func (c *Client) Replace(name string, ips []net.IP) error {
+	msg, err := c.updateMessage(name, ips)
+	if err != nil {
+		return err
+	}
+
+	if c.KeyName != "" {
+		msg, err = sign(msg, c.KeyName, c.Secret, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.exchange(msg)
+}
+
+// Delete removes the A and AAAA records of the name
+func (c *Client) Delete(name string) error {
+	return c.Replace(name, nil)
+}
+
+// updateMessage builds the update deleting the address records of the name, and adding the given ones
+func (c *Client) updateMessage(name string, ips []net.IP) ([]byte, error) {
+	zone, err := dnsmessage.NewName(fqdn(c.Zone))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zone %s: %w", c.Zone, err)
+	}
+	owner, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %s: %w", name, err)
+	}
+	if !InZone(name, c.Zone) {
+		return nil, fmt.Errorf("name %s is not in zone %s", name, c.Zone)
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:     uint16(rand.Intn(1 << 16)),
+		OpCode: opCodeUpdate,
+	})
+
+	// the zone section
+	err = b.StartQuestions()
+	if err != nil {
+		return nil, err
+	}
+	err = b.Question(dnsmessage.Question{
+		Name:  zone,
+		Type:  dnsmessage.TypeSOA,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the update section, there is no prerequisite
+	err = b.StartAuthorities()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		// deletes the whole RRset
+		err := b.UnknownResource(dnsmessage.ResourceHeader{
+			Name:  owner,
+			Type:  t,
+			Class: dnsmessage.ClassANY,
+		}, dnsmessage.UnknownResource{
+			Type: t,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, ip := range ips {
+		header := dnsmessage.ResourceHeader{
+			Name:  owner,
+			Class: dnsmessage.ClassINET,
+			TTL:   c.TTL,
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			r := dnsmessage.AResource{}
+			copy(r.A[:], ip4)
+			err = b.AResource(header, r)
+		} else if ip16 := ip.To16(); ip16 != nil {
+			r := dnsmessage.AAAAResource{}
+			copy(r.AAAA[:], ip16)
+			err = b.AAAAResource(header, r)
+		} else {
+			err = fmt.Errorf("invalid address %s", ip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Finish()
+}
+
+// exchange sends the update to the server, and checks its response
+func (c *Client) exchange(msg []byte) error {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", c.Server, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+
+	err = writeMessage(conn, msg)
+	if err != nil {
+		return fmt.Errorf("unable to send update: %w", err)
+	}
+
+	resp, err := readMessage(conn)
+	if err != nil {
+		return fmt.Errorf("unable to read response: %w", err)
+	}
+
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if header.ID != binary.BigEndian.Uint16(msg) {
+		return fmt.Errorf("response ID %d does not match update ID %d", header.ID, binary.BigEndian.Uint16(msg))
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("update refused by %s: %s", c.Server, header.RCode)
+	}
+	return nil
+}
+
+// writeMessage writes a message prefixed with its length, as DNS over TCP does
+func writeMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
+
+// readMessage reads a message prefixed with its length
+func readMessage(r io.Reader) ([]byte, error) {
+	length := make([]byte, 2)
+	_, err := io.ReadFull(r, length)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	_, err = io.ReadFull(r, msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// sign appends the TSIG record of the message, as described in RFC 8945
+func sign(msg []byte, keyName string, secret []byte, now time.Time) ([]byte, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("message too short")
+	}
+	name, err := wireName(keyName)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := wireName(tsigAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	timeSigned := make([]byte, 8)
+	binary.BigEndian.PutUint64(timeSigned, uint64(now.Unix()))
+	timers := append(timeSigned[2:], 0, 0)
+	binary.BigEndian.PutUint16(timers[6:], tsigFudge)
+
+	// the TSIG variables: name, class ANY, TTL 0, algorithm, timers, error 0 and no other data
+	variables := append([]byte{}, name...)
+	variables = append(variables, 0, byte(dnsmessage.ClassANY), 0, 0, 0, 0)
+	variables = append(variables, algorithm...)
+	variables = append(variables, timers...)
+	variables = append(variables, 0, 0, 0, 0)
+
+	h := hmac.New(sha256.New, secret)
+	h.Write(msg)
+	h.Write(variables)
+	mac := h.Sum(nil)
+
+	rdata := append([]byte{}, algorithm...)
+	rdata = append(rdata, timers...)
+	rdata = append(rdata, byte(len(mac)>>8), byte(len(mac)))
+	rdata = append(rdata, mac...)
+	// original ID, error 0 and no other data
+	rdata = append(rdata, msg[0], msg[1], 0, 0, 0, 0)
+
+	record := append([]byte{}, name...)
+	record = append(record, byte(typeTSIG>>8), byte(typeTSIG), 0, byte(dnsmessage.ClassANY), 0, 0, 0, 0)
+	record = append(record, byte(len(rdata)>>8), byte(len(rdata)))
+	record = append(record, rdata...)
+
+	signed := append(append([]byte{}, msg...), record...)
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	return signed, nil
+}
+
+// wireName returns the uncompressed, lowercase wire format of the name
+func wireName(name string) ([]byte, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	wire := []byte{}
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid name %s", name)
+			}
+			wire = append(wire, byte(len(label)))
+			wire = append(wire, label...)
+		}
+	}
+	return append(wire, 0), nil
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// InZone returns whether the name is the zone or one of its subdomains
+func InZone(name, zone string) bool {
+	name, zone = strings.ToLower(fqdn(name)), strings.ToLower(fqdn(zone))
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
@@ -0,0 +1,247 @@
+package dnsupdate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// update is an update received by the test server
+type update struct {
+	header  dnsmessage.Header
+	zone    string
+	deletes []string
+	adds    []string
+	tsig    []byte
+	signed  []byte
+}
+
+// serve runs a DNS server on localhost answering the updates with the given code
+func serve(t *testing.T, rcode dnsmessage.RCode) (string, <-chan update) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	updates := make(chan update, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		msg, err := readMessage(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		u, err := parseUpdate(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		updates <- u
+
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+			ID:       u.header.ID,
+			Response: true,
+			OpCode:   opCodeUpdate,
+			RCode:    rcode,
+		})
+		resp, err := b.Finish()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = writeMessage(conn, resp)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	return l.Addr().String(), updates
+}
+
+func parseUpdate(msg []byte) (update, error) {
+	u := update{}
+	var p dnsmessage.Parser
+	header, err := p.Start(msg)
+	if err != nil {
+		return u, err
+	}
+	u.header = header
+
+	q, err := p.Question()
+	if err != nil {
+		return u, err
+	}
+	u.zone = q.Name.String()
+	err = p.SkipAllQuestions()
+	if err != nil {
+		return u, err
+	}
+	err = p.SkipAllAnswers()
+	if err != nil {
+		return u, err
+	}
+
+	for {
+		h, err := p.AuthorityHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return u, err
+		}
+		switch {
+		case h.Class == dnsmessage.ClassANY:
+			u.deletes = append(u.deletes, h.Name.String()+" "+h.Type.String())
+			err = p.SkipAuthority()
+		case h.Type == dnsmessage.TypeA:
+			var r dnsmessage.AResource
+			r, err = p.AResource()
+			u.adds = append(u.adds, h.Name.String()+" "+net.IP(r.A[:]).String())
+		case h.Type == dnsmessage.TypeAAAA:
+			var r dnsmessage.AAAAResource
+			r, err = p.AAAAResource()
+			u.adds = append(u.adds, h.Name.String()+" "+net.IP(r.AAAA[:]).String())
+		default:
+			err = p.SkipAuthority()
+		}
+		if err != nil {
+			return u, err
+		}
+	}
+
+	if header, err := p.AdditionalHeader(); err == nil && header.Type == typeTSIG {
+		r, err := p.UnknownResource()
+		if err != nil {
+			return u, err
+		}
+		u.tsig = r.Data
+		u.signed = msg
+	}
+	return u, nil
+}
+
+func TestReplace(t *testing.T) {
+	server, updates := serve(t, dnsmessage.RCodeSuccess)
+
+	c := &Client{
+		Server: server,
+		Zone:   "example.com",
+		TTL:    300,
+	}
+	err := c.Replace("node-1.pn.example.com", []net.IP{net.ParseIP("192.168.0.2"), net.ParseIP("fd00::2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := <-updates
+	if u.header.OpCode != opCodeUpdate {
+		t.Errorf("expected opcode %d, got %d", opCodeUpdate, u.header.OpCode)
+	}
+	if u.zone != "example.com." {
+		t.Errorf("expected zone example.com., got %s", u.zone)
+	}
+	expectedDeletes := []string{"node-1.pn.example.com. TypeA", "node-1.pn.example.com. TypeAAAA"}
+	if !equal(u.deletes, expectedDeletes) {
+		t.Errorf("expected deletes %v, got %v", expectedDeletes, u.deletes)
+	}
+	expectedAdds := []string{"node-1.pn.example.com. 192.168.0.2", "node-1.pn.example.com. fd00::2"}
+	if !equal(u.adds, expectedAdds) {
+		t.Errorf("expected adds %v, got %v", expectedAdds, u.adds)
+	}
+	if u.tsig != nil {
+		t.Errorf("expected an unsigned update")
+	}
+}
+
+func TestDeleteSigned(t *testing.T) {
+	server, updates := serve(t, dnsmessage.RCodeSuccess)
+
+	secret := []byte("secret")
+	c := &Client{
+		Server:  server,
+		Zone:    "example.com.",
+		KeyName: "k8s-vpc.",
+		Secret:  secret,
+	}
+	err := c.Delete("node-1.pn.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := <-updates
+	if len(u.adds) != 0 {
+		t.Errorf("expected no adds, got %v", u.adds)
+	}
+	if len(u.deletes) != 2 {
+		t.Errorf("expected 2 deletes, got %v", u.deletes)
+	}
+	if u.tsig == nil {
+		t.Fatal("expected a signed update")
+	}
+
+	// the MAC covers the message without the TSIG record, and the TSIG variables
+	name, _ := wireName("k8s-vpc.")
+	record := len(name) + 10 + len(u.tsig)
+	unsigned := append([]byte{}, u.signed[:len(u.signed)-record]...)
+	unsigned[11]--
+	algorithm, _ := wireName(tsigAlgorithm)
+	timers := u.tsig[len(algorithm) : len(algorithm)+8]
+	mac := u.tsig[len(algorithm)+10 : len(algorithm)+10+sha256.Size]
+
+	variables := append([]byte{}, name...)
+	variables = append(variables, 0, byte(dnsmessage.ClassANY), 0, 0, 0, 0)
+	variables = append(variables, algorithm...)
+	variables = append(variables, timers...)
+	variables = append(variables, 0, 0, 0, 0)
+	h := hmac.New(sha256.New, secret)
+	h.Write(unsigned)
+	h.Write(variables)
+	if !hmac.Equal(mac, h.Sum(nil)) {
+		t.Errorf("invalid TSIG MAC")
+	}
+}
+
+func TestRefused(t *testing.T) {
+	server, updates := serve(t, dnsmessage.RCodeRefused)
+
+	c := &Client{
+		Server: server,
+		Zone:   "example.com",
+	}
+	err := c.Replace("node-1.pn.example.com", []net.IP{net.ParseIP("192.168.0.2")})
+	<-updates
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestNotInZone(t *testing.T) {
+	c := &Client{
+		Zone: "example.com",
+	}
+	err := c.Replace("node-1.pn.example.org", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
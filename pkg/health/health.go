@@ -0,0 +1,29 @@
+package health
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// CachedChecker returns a checker running the check at most once per interval, so that
+// probes don't hammer the checked API
+func CachedChecker(interval time.Duration, check func() error) healthz.Checker {
+	var lock sync.Mutex
+	var lastCheck time.Time
+	var lastErr error
+
+	return func(_ *http.Request) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if time.Since(lastCheck) < interval {
+			return lastErr
+		}
+		lastErr = check()
+		lastCheck = time.Now()
+		return lastErr
+	}
+}
@@ -3,6 +3,7 @@ package ipam
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +26,7 @@ var (
 type ConfigMapIPAM struct {
 	name   types.NamespacedName
 	client client.Client
+	cache  cache.Cache
 
 	lock sync.RWMutex
 }
@@ -95,9 +97,27 @@ func NewConfigMapIPAM(name types.NamespacedName, stopCh <-chan struct{}) (*Confi
 	return &ConfigMapIPAM{
 		name:   name,
 		client: cmCacheClient,
+		cache:  cmCache,
 	}, nil
 }
 
+// Check returns an error if the configmap cache is not synced or the configmap can't be read
+func (c *ConfigMapIPAM) Check(_ *http.Request) error {
+	// the stop channel is closed so that the sync status is returned right away
+	stop := make(chan struct{})
+	close(stop)
+	if !c.cache.WaitForCacheSync(stop) {
+		return fmt.Errorf("configmap cache not synced")
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := c.client.Get(context.Background(), c.name, cm)
+	if err != nil {
+		return fmt.Errorf("unable to get ipam configmap: %w", err)
+	}
+	return nil
+}
+
 func encode(prefix *goipam.Prefix) ([]byte, error) {
 	return prefix.GobEncode()
 }
@@ -0,0 +1,114 @@
+package ipam
+
+import (
+	"fmt"
+	"sync"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+// Snapshot is an in-memory copy of the prefixes of a storage. It is used to compute allocations,
+// like the next free IPs, without modifying the storage.
+type Snapshot struct {
+	prefixes map[string][]byte
+
+	lock sync.RWMutex
+}
+
+// PrefixReader reads all the prefixes of a storage
+type PrefixReader interface {
+	ReadAllPrefixes() ([]goipam.Prefix, error)
+}
+
+// NewSnapshot copies the prefixes of the storage
+func NewSnapshot(storage PrefixReader) (*Snapshot, error) {
+	prefixes, err := storage.ReadAllPrefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{
+		prefixes: make(map[string][]byte, len(prefixes)),
+	}
+	for _, prefix := range prefixes {
+		data, err := encode(&prefix)
+		if err != nil {
+			return nil, err
+		}
+		s.prefixes[prefix.Cidr] = data
+	}
+	return s, nil
+}
+
+func (s *Snapshot) CreatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if data, ok := s.prefixes[prefix.Cidr]; ok {
+		p, err := decode(data)
+		if err != nil {
+			return goipam.Prefix{}, err
+		}
+		return *p, nil
+	}
+
+	data, err := encode(&prefix)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+	s.prefixes[prefix.Cidr] = data
+	return prefix, nil
+}
+
+func (s *Snapshot) ReadPrefix(prefix string) (goipam.Prefix, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, ok := s.prefixes[prefix]
+	if !ok {
+		return goipam.Prefix{}, fmt.Errorf("prefix %s not found", prefix)
+	}
+	p, err := decode(data)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+	return *p, nil
+}
+
+func (s *Snapshot) ReadAllPrefixes() ([]goipam.Prefix, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ps := make([]goipam.Prefix, 0, len(s.prefixes))
+	for _, data := range s.prefixes {
+		p, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, *p)
+	}
+	return ps, nil
+}
+
+func (s *Snapshot) UpdatePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.prefixes[prefix.Cidr]; !ok {
+		return goipam.Prefix{}, fmt.Errorf("prefix %s not found", prefix.Cidr)
+	}
+	data, err := encode(&prefix)
+	if err != nil {
+		return goipam.Prefix{}, err
+	}
+	s.prefixes[prefix.Cidr] = data
+	return prefix, nil
+}
+
+func (s *Snapshot) DeletePrefix(prefix goipam.Prefix) (goipam.Prefix, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.prefixes, prefix.Cidr)
+	return prefix, nil
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+var (
+	metricsLog = ctrl.Log.WithName("metrics")
+
+	prefixSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ipam", "prefix_size"),
+		"Number of addresses in an IPAM prefix of a PrivateNetwork.",
+		[]string{"private_network", "cidr"}, nil,
+	)
+	prefixUsedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ipam", "prefix_used"),
+		"Number of acquired addresses in an IPAM prefix of a PrivateNetwork.",
+		[]string{"private_network", "cidr"}, nil,
+	)
+	prefixFreeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ipam", "prefix_free"),
+		"Number of free addresses in an IPAM prefix of a PrivateNetwork.",
+		[]string{"private_network", "cidr"}, nil,
+	)
+	networkInterfacesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "network_interfaces"),
+		"Number of NetworkInterfaces by PrivateNetwork and phase.",
+		[]string{"private_network", "phase"}, nil,
+	)
+)
+
+// Collector collects the IPAM usage and the NetworkInterfaces phases on each scrape
+type Collector struct {
+	Client client.Reader
+	IPAM   goipam.Ipamer
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prefixSizeDesc
+	ch <- prefixUsedDesc
+	ch <- prefixFreeDesc
+	ch <- networkInterfacesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	pnsList := &vpcv1alpha1.PrivateNetworkList{}
+	err := c.Client.List(ctx, pnsList)
+	if err != nil {
+		metricsLog.Error(err, "unable to list privateNetworks")
+		return
+	}
+
+	for _, pn := range pnsList.Items {
+		for _, cidr := range prefixesOf(&pn) {
+			prefix := c.IPAM.PrefixFrom(cidr)
+			if prefix == nil {
+				continue
+			}
+			usage := prefix.Usage()
+			ch <- prometheus.MustNewConstMetric(prefixSizeDesc, prometheus.GaugeValue, float64(usage.AvailableIPs), pn.Name, cidr)
+			ch <- prometheus.MustNewConstMetric(prefixUsedDesc, prometheus.GaugeValue, float64(usage.AcquiredIPs), pn.Name, cidr)
+			ch <- prometheus.MustNewConstMetric(prefixFreeDesc, prometheus.GaugeValue, float64(usage.AvailableIPs-usage.AcquiredIPs), pn.Name, cidr)
+		}
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = c.Client.List(ctx, nicsList)
+	if err != nil {
+		metricsLog.Error(err, "unable to list networkInterfaces")
+		return
+	}
+
+	type key struct {
+		pn    string
+		phase vpcv1alpha1.NetworkInterfacePhase
+	}
+	counts := make(map[key]int)
+	for _, nic := range nicsList.Items {
+		phase := nic.Status.Phase
+		if phase == "" {
+			phase = vpcv1alpha1.NetworkInterfacePhasePending
+		}
+		counts[key{pn: nic.Labels[constants.PrivateNetworkLabel], phase: phase}]++
+	}
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(networkInterfacesDesc, prometheus.GaugeValue, float64(count), k.pn, string(k.phase))
+	}
+}
+
+// prefixesOf returns the IPAM prefixes used by the PrivateNetwork
+func prefixesOf(pn *vpcv1alpha1.PrivateNetwork) []string {
+	if pn.Spec.CIDR != "" {
+		return []string{pn.Spec.CIDR}
+	}
+	if pn.Spec.IPAM == nil || pn.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeStatic || pn.Spec.IPAM.Static == nil {
+		return nil
+	}
+	if len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
+		return pn.Spec.IPAM.Static.AvailableRanges
+	}
+	return []string{pn.Spec.IPAM.Static.CIDR}
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	goipam "github.com/metal-stack/go-ipam"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+func TestPrefixesOf(t *testing.T) {
+	tests := []struct {
+		name string
+		spec vpcv1alpha1.PrivateNetworkSpec
+		want []string
+	}{
+		{
+			name: "no ipam",
+		},
+		{
+			name: "deprecated cidr",
+			spec: vpcv1alpha1.PrivateNetworkSpec{CIDR: "10.0.0.0/24"},
+			want: []string{"10.0.0.0/24"},
+		},
+		{
+			name: "dhcp",
+			spec: vpcv1alpha1.PrivateNetworkSpec{IPAM: &vpcv1alpha1.PrivateNetworkIPAM{Type: vpcv1alpha1.IPAMTypeDHCP}},
+		},
+		{
+			name: "static cidr",
+			spec: vpcv1alpha1.PrivateNetworkSpec{IPAM: &vpcv1alpha1.PrivateNetworkIPAM{
+				Type:   vpcv1alpha1.IPAMTypeStatic,
+				Static: &vpcv1alpha1.PrivateNetworkIPAMStatic{CIDR: "10.0.0.0/16"},
+			}},
+			want: []string{"10.0.0.0/16"},
+		},
+		{
+			name: "available ranges",
+			spec: vpcv1alpha1.PrivateNetworkSpec{IPAM: &vpcv1alpha1.PrivateNetworkIPAM{
+				Type: vpcv1alpha1.IPAMTypeStatic,
+				Static: &vpcv1alpha1.PrivateNetworkIPAMStatic{
+					CIDR:            "10.0.0.0/16",
+					AvailableRanges: []string{"10.0.0.0/24", "10.0.1.0/24"},
+				},
+			}},
+			want: []string{"10.0.0.0/24", "10.0.1.0/24"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := prefixesOf(&vpcv1alpha1.PrivateNetwork{Spec: test.spec})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("prefixes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func networkInterface(name string, pn string, phase vpcv1alpha1.NetworkInterfacePhase) *vpcv1alpha1.NetworkInterface {
+	return &vpcv1alpha1.NetworkInterface{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{constants.PrivateNetworkLabel: pn},
+		},
+		Status: vpcv1alpha1.NetworkInterfaceStatus{Phase: phase},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	scheme := runtime.NewScheme()
+	err := vpcv1alpha1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipam := goipam.New()
+	prefix, err := ipam.NewPrefix("10.0.0.0/28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := ipam.AcquireIP(prefix.Cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &Collector{
+		Client: fake.NewFakeClientWithScheme(scheme,
+			&vpcv1alpha1.PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "static"},
+				Spec: vpcv1alpha1.PrivateNetworkSpec{IPAM: &vpcv1alpha1.PrivateNetworkIPAM{
+					Type: vpcv1alpha1.IPAMTypeStatic,
+					Static: &vpcv1alpha1.PrivateNetworkIPAMStatic{
+						CIDR: "10.0.0.0/16",
+						// the second range is not created in the IPAM yet
+						AvailableRanges: []string{"10.0.0.0/28", "10.0.1.0/28"},
+					},
+				}},
+			},
+			&vpcv1alpha1.PrivateNetwork{
+				ObjectMeta: metav1.ObjectMeta{Name: "dhcp"},
+				Spec:       vpcv1alpha1.PrivateNetworkSpec{IPAM: &vpcv1alpha1.PrivateNetworkIPAM{Type: vpcv1alpha1.IPAMTypeDHCP}},
+			},
+			networkInterface("static-1", "static", vpcv1alpha1.NetworkInterfacePhaseReady),
+			networkInterface("static-2", "static", vpcv1alpha1.NetworkInterfacePhaseReady),
+			networkInterface("static-3", "static", ""),
+			networkInterface("dhcp-1", "dhcp", vpcv1alpha1.NetworkInterfacePhaseDegraded),
+		),
+		IPAM: ipam,
+	}
+
+	// the network and broadcast addresses are acquired when the prefix is created
+	expected := `
+# HELP scaleway_k8s_vpc_ipam_prefix_free Number of free addresses in an IPAM prefix of a PrivateNetwork.
+# TYPE scaleway_k8s_vpc_ipam_prefix_free gauge
+scaleway_k8s_vpc_ipam_prefix_free{cidr="10.0.0.0/28",private_network="static"} 11
+# HELP scaleway_k8s_vpc_ipam_prefix_size Number of addresses in an IPAM prefix of a PrivateNetwork.
+# TYPE scaleway_k8s_vpc_ipam_prefix_size gauge
+scaleway_k8s_vpc_ipam_prefix_size{cidr="10.0.0.0/28",private_network="static"} 16
+# HELP scaleway_k8s_vpc_ipam_prefix_used Number of acquired addresses in an IPAM prefix of a PrivateNetwork.
+# TYPE scaleway_k8s_vpc_ipam_prefix_used gauge
+scaleway_k8s_vpc_ipam_prefix_used{cidr="10.0.0.0/28",private_network="static"} 5
+# HELP scaleway_k8s_vpc_network_interfaces Number of NetworkInterfaces by PrivateNetwork and phase.
+# TYPE scaleway_k8s_vpc_network_interfaces gauge
+scaleway_k8s_vpc_network_interfaces{phase="Degraded",private_network="dhcp"} 1
+scaleway_k8s_vpc_network_interfaces{phase="Pending",private_network="static"} 1
+scaleway_k8s_vpc_network_interfaces{phase="Ready",private_network="static"} 2
+`
+	err = testutil.CollectAndCompare(c, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "scaleway_k8s_vpc"
+)
+
+var (
+	// ScalewayRequests counts the Scaleway API requests by method and status code
+	ScalewayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scaleway_api",
+		Name:      "requests_total",
+		Help:      "Number of requests to the Scaleway API by method and status code.",
+	}, []string{"method", "code"})
+
+	// ScalewayRequestDuration observes the latency of the Scaleway API requests by method
+	ScalewayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scaleway_api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests to the Scaleway API by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// LinkConfigureFailures counts the failures to configure a link on the node
+	LinkConfigureFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "link_configure_failures_total",
+		Help:      "Number of failures to configure a private network link.",
+	}, []string{"private_network"})
+
+	// LinkTearDownFailures counts the failures to tear down a link on the node
+	LinkTearDownFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "link_teardown_failures_total",
+		Help:      "Number of failures to tear down a private network link.",
+	}, []string{"private_network"})
+
+	// DHCPRenewals counts the DHCP lease renewals seen on the node
+	DHCPRenewals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "dhcp_renewals_total",
+		Help:      "Number of DHCP lease renewals on a private network link.",
+	}, []string{"private_network"})
+
+	// RouteChanges counts the routes added and deleted when syncing the routes on the node
+	RouteChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "route_changes_total",
+		Help:      "Number of routes added or deleted on a private network link.",
+	}, []string{"private_network", "operation"})
+
+	// ProbeReachable reports whether the health check targets are reachable from the node
+	ProbeReachable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "probe_reachable",
+		Help:      "Whether a health check target is reachable through a private network link.",
+	}, []string{"private_network", "target"})
+
+	// ProbeLatency reports the latency of the last successful probe of the health check targets
+	ProbeLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "probe_latency_seconds",
+		Help:      "Latency of the last successful probe of a health check target through a private network link.",
+	}, []string{"private_network", "target"})
+)
+
+// RegisterController registers the metrics of the controller
+func RegisterController(collectors ...prometheus.Collector) {
+	ctrlmetrics.Registry.MustRegister(ScalewayRequests, ScalewayRequestDuration)
+	ctrlmetrics.Registry.MustRegister(collectors...)
+}
+
+// RegisterNode registers the metrics of the node daemon
+func RegisterNode() {
+	ctrlmetrics.Registry.MustRegister(LinkConfigureFailures, LinkTearDownFailures, DHCPRenewals, RouteChanges, ProbeReachable, ProbeLatency)
+}
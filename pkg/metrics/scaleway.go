@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	uuidRegexp = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// ScalewayTransport is an http.RoundTripper recording metrics about the Scaleway API requests
+type ScalewayTransport struct {
+	Transport http.RoundTripper
+}
+
+// NewScalewayHTTPClient returns an http client recording metrics about the Scaleway API requests
+func NewScalewayHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &ScalewayTransport{
+			Transport: http.DefaultTransport,
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ScalewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := requestMethod(req)
+
+	start := time.Now()
+	resp, err := t.Transport.RoundTrip(req)
+	ScalewayRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	ScalewayRequests.WithLabelValues(method, code).Inc()
+
+	return resp, err
+}
+
+// requestMethod returns the API method of the request, e.g. `GET /instance/v1/zones/{zone}/servers/{id}`.
+// The IDs and the localities are replaced to keep a low cardinality.
+func requestMethod(req *http.Request) string {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for i, part := range parts {
+		if i > 0 {
+			switch parts[i-1] {
+			case "zones":
+				parts[i] = "{zone}"
+				continue
+			case "regions":
+				parts[i] = "{region}"
+				continue
+			}
+		}
+		if uuidRegexp.MatchString(part) {
+			parts[i] = "{id}"
+		}
+	}
+	return req.Method + " /" + strings.Join(parts, "/")
+}
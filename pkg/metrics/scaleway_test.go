@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{
+			name:   "zonal resource",
+			method: http.MethodGet,
+			url:    "https://api.scaleway.com/instance/v1/zones/fr-par-1/servers/11111111-2222-3333-4444-555555555555",
+			want:   "GET /instance/v1/zones/{zone}/servers/{id}",
+		},
+		{
+			name:   "regional collection",
+			method: http.MethodPost,
+			url:    "https://api.scaleway.com/vpc/v2/regions/nl-ams/private-networks",
+			want:   "POST /vpc/v2/regions/{region}/private-networks",
+		},
+		{
+			name:   "nested resources",
+			method: http.MethodDelete,
+			url:    "https://api.scaleway.com/instance/v1/zones/pl-waw-2/servers/11111111-2222-3333-4444-555555555555/private_nics/aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+			want:   "DELETE /instance/v1/zones/{zone}/servers/{id}/private_nics/{id}",
+		},
+		{
+			name:   "query is ignored",
+			method: http.MethodGet,
+			url:    "https://api.scaleway.com/instance/v1/zones/fr-par-1/servers?page=2&per_page=50",
+			want:   "GET /instance/v1/zones/{zone}/servers",
+		},
+		{
+			name:   "uppercase uuid is replaced",
+			method: http.MethodGet,
+			url:    "https://api.scaleway.com/vpc/v1/private-networks/AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE",
+			want:   "GET /vpc/v1/private-networks/{id}",
+		},
+		{
+			name:   "trailing slash",
+			method: http.MethodGet,
+			url:    "https://api.scaleway.com/account/v2/projects/",
+			want:   "GET /account/v2/projects",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := requestMethod(req)
+			if got != test.want {
+				t.Errorf("requestMethod = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
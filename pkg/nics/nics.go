@@ -6,13 +6,16 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/vishvananda/netlink"
 )
 
 const (
-	dhcpcdRunFilePrefix = "/var/run/dhcpcd-"
-	dhcpcdRunFileSuffix = "-4.pid"
+	dhcpcdRunFilePrefix   = "/var/run/dhcpcd-"
+	dhcpcdRunFileSuffix   = "-4.pid"
+	dhcpcdLeaseFilePrefix = "/var/lib/dhcpcd/"
+	dhcpcdLeaseFileSuffix = ".lease"
 )
 
 var (
@@ -75,6 +78,12 @@ func NewNICs(macs []string) (*NICs, error) {
 	return nics, nil
 }
 
+// Check returns an error if the netlink handle is not usable
+func (n *NICs) Check() error {
+	_, err := n.Handle.LinkList()
+	return err
+}
+
 func (n *NICs) GetLinkName(mac string) (string, error) {
 	link, err := n.getLink(mac)
 	if err != nil {
@@ -83,6 +92,42 @@ func (n *NICs) GetLinkName(mac string) (string, error) {
 	return link.Attrs().Name, nil
 }
 
+// SetLinkName renames the link with the given mac address.
+// A running dhcpcd is stopped beforehand as it is bound to the previous name.
+func (n *NICs) SetLinkName(mac string, name string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	if link.Attrs().Name == name {
+		return nil
+	}
+
+	err = stopDHCPCD(link.Attrs().Name)
+	if err != nil {
+		return err
+	}
+
+	err = netlink.LinkSetDown(link)
+	if err != nil {
+		return err
+	}
+
+	err = netlink.LinkSetName(link, name)
+	if err != nil {
+		return err
+	}
+
+	renamedLink, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	n.Links[mac] = renamedLink
+
+	return nil
+}
+
 func (n *NICs) getLink(mac string) (netlink.Link, error) {
 	if link, ok := n.Links[mac]; ok {
 		return link, nil
@@ -199,16 +244,63 @@ func (n *NICs) TearDownDHCPLink(mac string) error {
 		return err
 	}
 
-	_, err = os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix)
-	if err != nil && !os.IsNotExist(err) {
+	err = stopDHCPCD(link.Attrs().Name)
+	if err != nil {
 		return err
 	}
 
-	if err == nil {
-		cmd := exec.Command("dhcpcd", "-A4", "--waitip", "-C", "resolv.conf", "-G", "-k", link.Attrs().Name)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+	err = netlink.LinkSetDown(link)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// stopDHCPCD stops dhcpcd on the link with the given name, if it's running
+func stopDHCPCD(name string) error {
+	_, err := os.Stat(dhcpcdRunFilePrefix + name + dhcpcdRunFileSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	cmd := exec.Command("dhcpcd", "-A4", "--waitip", "-C", "resolv.conf", "-G", "-k", name)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// TearDownLink removes the whole configuration of the link with the given mac address:
+// dhcpcd is stopped, the routes and addresses are removed and the link is set down
+func (n *NICs) TearDownLink(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+
+	err = stopDHCPCD(link.Attrs().Name)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = n.SyncRoutes(mac, nil)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		err := netlink.AddrDel(link, &addr)
+		if err != nil {
 			return err
 		}
 	}
@@ -221,6 +313,70 @@ func (n *NICs) TearDownDHCPLink(mac string) error {
 }
 
 func (n *NICs) TearDownStaticLink(mac string, ip string) error {
+	err := n.RemoveAddress(mac, ip)
+	if err != nil {
+		return err
+	}
+
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+
+	err = netlink.LinkSetDown(link)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddAddress adds the address to the link with the given mac address, if it's not already there
+func (n *NICs) AddAddress(mac string, ip string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	ipnet, err := netlink.ParseIPNet(ip)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if maskEqual(addr.IPNet.Mask, ipnet.Mask) && addr.IPNet.IP.Equal(ipnet.IP) {
+			return nil
+		}
+	}
+
+	return netlink.AddrAdd(link, &netlink.Addr{
+		IPNet: ipnet,
+	})
+}
+
+// Announce sends gratuitous ARP replies for the ip on the link with the given mac address,
+// so that the neighbours update their cache
+func (n *NICs) Announce(mac string, ip string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("arping", "-U", "-c", "3", "-I", link.Attrs().Name, ip)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// RemoveAddress removes the address from the link with the given mac address, leaving the link up
+func (n *NICs) RemoveAddress(mac string, ip string) error {
 	link, err := n.getLink(mac)
 	if err != nil {
 		if errors.Is(err, nicNotFoundErr) {
@@ -255,31 +411,30 @@ func (n *NICs) TearDownStaticLink(mac string, ip string) error {
 			return err
 		}
 	}
-
-	err = netlink.LinkSetDown(link)
-	if err != nil {
-		return err
-	}
 	return nil
 }
 
-func (n *NICs) SyncRoutes(mac string, routes []Route) error {
+// SyncRoutes makes sure the routes of the link are the given ones, and returns the number of added and deleted routes
+func (n *NICs) SyncRoutes(mac string, routes []Route) (int, int, error) {
 	link, err := n.getLink(mac)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	existingRoutes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
+	added, deleted := 0, 0
+
 	for _, existingRoute := range existingRoutes {
 		if !isIn(existingRoute, routes) && existingRoute.Src == nil {
 			err := netlink.RouteDel(&existingRoute)
 			if err != nil {
-				return err
+				return added, deleted, err
 			}
+			deleted++
 		}
 	}
 
@@ -291,9 +446,101 @@ func (n *NICs) SyncRoutes(mac string, routes []Route) error {
 				Gw:        route.Via,
 			})
 			if err != nil {
-				return err
+				return added, deleted, err
 			}
+			added++
 		}
 	}
-	return nil
+	return added, deleted, nil
+}
+
+// GetAddresses returns the IPv4 addresses of the link with the given mac address, in CIDR notation
+func (n *NICs) GetAddresses(mac string) ([]string, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		addresses = append(addresses, addr.IPNet.String())
+	}
+	return addresses, nil
+}
+
+// GetRoutes returns the routes of the link with the given mac address, the routes added by the kernel
+// for the addresses of the link are ignored
+func (n *NICs) GetRoutes(mac string) ([]Route, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	existingRoutes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []Route{}
+	for _, route := range existingRoutes {
+		if route.Src != nil || route.Dst == nil {
+			continue
+		}
+		routes = append(routes, Route{
+			To:  route.Dst,
+			Via: route.Gw,
+		})
+	}
+	return routes, nil
+}
+
+// IsUp returns whether the link with the given mac address is up
+func (n *NICs) IsUp(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	// the cached link attributes may be outdated
+	link, err = n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return false, err
+	}
+	return link.Attrs().Flags&net.FlagUp != 0, nil
+}
+
+// IsDHCPRunning returns whether dhcpcd runs on the link with the given mac address
+func (n *NICs) IsDHCPRunning(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetDHCPLeaseTime returns the last time dhcpcd wrote the lease of the link
+func (n *NICs) GetDHCPLeaseTime(mac string) (time.Time, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	info, err := os.Stat(dhcpcdLeaseFilePrefix + link.Attrs().Name + dhcpcdLeaseFileSuffix)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
 }
@@ -0,0 +1,225 @@
+package podipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// DefaultSocketPath is the default path of the unix socket the node daemon serves the pod IPAM on
+	DefaultSocketPath = "/run/scaleway-k8s-vpc/ipam.sock"
+
+	// AllocatePath is the path of the allocation endpoint
+	AllocatePath = "/allocate"
+	// ReleasePath is the path of the release endpoint
+	ReleasePath = "/release"
+)
+
+// Request identifies the interface of a container in a PrivateNetwork
+type Request struct {
+	PrivateNetwork string `json:"privateNetwork"`
+	ContainerID    string `json:"containerID"`
+	IfName         string `json:"ifName"`
+}
+
+func (r Request) key() string {
+	return r.PrivateNetwork + "/" + r.ContainerID + "/" + r.IfName
+}
+
+// Route is a route given to the container
+type Route struct {
+	Dst string `json:"dst"`
+	Gw  string `json:"gw,omitempty"`
+}
+
+// Allocation is the address given to the interface of a container
+type Allocation struct {
+	// Address is the address in CIDR notation, with the mask of the PrivateNetwork
+	Address string  `json:"address"`
+	Routes  []Route `json:"routes,omitempty"`
+}
+
+// Store allocates the addresses of the containers from the pod CIDRs of the node,
+// and records them in a file to survive restarts
+type Store struct {
+	path string
+	lock sync.Mutex
+
+	// Addresses are the allocated IPs, by private network, container ID and interface name
+	Addresses map[string]string `json:"addresses"`
+}
+
+// LoadStore reads the allocations from the given file, an empty store is returned if the file does not exist
+func LoadStore(path string) (*Store, error) {
+	store := &Store{
+		path:      path,
+		Addresses: make(map[string]string),
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, store)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode pod ipam file %s: %w", path, err)
+	}
+	if store.Addresses == nil {
+		store.Addresses = make(map[string]string)
+	}
+	return store, nil
+}
+
+// Allocate returns the IP of the request, allocating the first free IP of podCIDR if needed.
+// subnet is the subnet the address is given in: podCIDR itself when the pods are routed through
+// the node, or the subnet of the PrivateNetwork when the pods are attached to it. Its network and
+// broadcast addresses are never allocated.
+func (s *Store) Allocate(req Request, podCIDR *net.IPNet, subnet *net.IPNet) (net.IP, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := req.key()
+	if ip := net.ParseIP(s.Addresses[key]); ip != nil && podCIDR.Contains(ip) {
+		return ip, nil
+	}
+
+	used := make(map[string]struct{})
+	for _, ip := range s.Addresses {
+		used[ip] = struct{}{}
+	}
+	if networkIP := subnet.IP.To4(); networkIP != nil {
+		broadcast := make(net.IP, 4)
+		for i := range networkIP {
+			broadcast[i] = networkIP[i] | ^subnet.Mask[len(subnet.Mask)-4+i]
+		}
+		used[networkIP.String()] = struct{}{}
+		used[broadcast.String()] = struct{}{}
+	}
+
+	ones, bits := podCIDR.Mask.Size()
+	if bits != 32 {
+		return nil, fmt.Errorf("pod cidr %s is not an IPv4 prefix", podCIDR)
+	}
+	first := binary.BigEndian.Uint32(podCIDR.IP.To4())
+	size := uint32(1) << uint(bits-ones)
+	for i := uint32(0); i < size; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, first+i)
+		if _, ok := used[ip.String()]; ok {
+			continue
+		}
+		s.Addresses[key] = ip.String()
+		err := s.save()
+		if err != nil {
+			delete(s.Addresses, key)
+			return nil, err
+		}
+		return ip, nil
+	}
+	return nil, fmt.Errorf("no free address left in pod cidr %s", podCIDR)
+}
+
+// Release releases the IP of the request, releasing an unknown request is not an error
+func (s *Store) Release(req Request) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := req.key()
+	if _, ok := s.Addresses[key]; !ok {
+		return nil
+	}
+	delete(s.Addresses, key)
+	return s.save()
+}
+
+// save writes the store to a temporary file before renaming it, to never leave a partial file
+func (s *Store) save() error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// Client talks to the pod IPAM of the node daemon over its unix socket
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the pod IPAM served on the given unix socket
+func NewClient(socketPath string) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Allocate requests an address for the interface of a container
+func (c *Client) Allocate(req Request) (*Allocation, error) {
+	allocation := &Allocation{}
+	err := c.do(AllocatePath, req, allocation)
+	if err != nil {
+		return nil, err
+	}
+	return allocation, nil
+}
+
+// Release releases the address of the interface of a container
+func (c *Client) Release(req Request) error {
+	return c.do(ReleasePath, req, nil)
+}
+
+func (c *Client) do(path string, req Request, out interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Post("http://unix"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pod ipam returned %s: %s", resp.Status, bytes.TrimSpace(content))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(content, out)
+}
@@ -0,0 +1,358 @@
+package podipam
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ipNet
+}
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := LoadStore(filepath.Join(t.TempDir(), "ipam", "pods.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func request(containerID string) Request {
+	return Request{
+		PrivateNetwork: "pn",
+		ContainerID:    containerID,
+		IfName:         "eth1",
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses map[string]string
+		podCIDR   string
+		subnet    string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:    "network address of the routed pod cidr is skipped",
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.1.0/30",
+			want:    "10.0.1.1",
+		},
+		{
+			name: "broadcast address of the routed pod cidr is skipped",
+			addresses: map[string]string{
+				"pn/a/eth1": "10.0.1.1",
+				"pn/b/eth1": "10.0.1.2",
+			},
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.1.0/30",
+			wantErr: true,
+		},
+		{
+			name:    "first address of the attached pod cidr",
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.0.0/16",
+			want:    "10.0.1.0",
+		},
+		{
+			name:    "network address of the private network subnet is skipped",
+			podCIDR: "10.0.0.0/30",
+			subnet:  "10.0.0.0/16",
+			want:    "10.0.0.1",
+		},
+		{
+			name: "broadcast address of the private network subnet is skipped",
+			addresses: map[string]string{
+				"pn/a/eth1": "10.0.255.252",
+				"pn/b/eth1": "10.0.255.253",
+				"pn/c/eth1": "10.0.255.254",
+			},
+			podCIDR: "10.0.255.252/30",
+			subnet:  "10.0.0.0/16",
+			wantErr: true,
+		},
+		{
+			name: "used addresses are skipped",
+			addresses: map[string]string{
+				"pn/a/eth1":    "10.0.1.0",
+				"other/b/eth1": "10.0.1.1",
+			},
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.0.0/16",
+			want:    "10.0.1.2",
+		},
+		{
+			name: "existing allocation is returned",
+			addresses: map[string]string{
+				"pn/a/eth1":         "10.0.1.0",
+				"pn/container/eth1": "10.0.1.3",
+			},
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.0.0/16",
+			want:    "10.0.1.3",
+		},
+		{
+			name: "allocation outside of the pod cidr is replaced",
+			addresses: map[string]string{
+				"pn/container/eth1": "10.0.2.1",
+			},
+			podCIDR: "10.0.1.0/30",
+			subnet:  "10.0.0.0/16",
+			want:    "10.0.1.0",
+		},
+		{
+			name: "pod cidr is full",
+			addresses: map[string]string{
+				"pn/a/eth1": "10.0.1.0",
+				"pn/b/eth1": "10.0.1.1",
+			},
+			podCIDR: "10.0.1.0/31",
+			subnet:  "10.0.0.0/16",
+			wantErr: true,
+		},
+		{
+			name:    "ipv6 pod cidr",
+			podCIDR: "fd00::/120",
+			subnet:  "fd00::/64",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newStore(t)
+			for key, ip := range test.addresses {
+				store.Addresses[key] = ip
+			}
+
+			ip, err := store.Allocate(request("container"), mustParseCIDR(t, test.podCIDR), mustParseCIDR(t, test.subnet))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", ip)
+				}
+				if _, ok := store.Addresses["pn/container/eth1"]; ok && test.addresses["pn/container/eth1"] == "" {
+					t.Errorf("failed allocation was recorded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != test.want {
+				t.Errorf("allocated %s, want %s", ip, test.want)
+			}
+			if store.Addresses["pn/container/eth1"] != test.want {
+				t.Errorf("recorded %s, want %s", store.Addresses["pn/container/eth1"], test.want)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	podCIDR := mustParseCIDR(t, "10.0.1.0/30")
+	subnet := mustParseCIDR(t, "10.0.0.0/16")
+
+	tests := []struct {
+		name    string
+		release Request
+		want    string
+	}{
+		{
+			name:    "released address is allocated again",
+			release: request("a"),
+			want:    "10.0.1.0",
+		},
+		{
+			name:    "releasing an unknown request is not an error",
+			release: request("unknown"),
+			want:    "10.0.1.2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newStore(t)
+			for _, containerID := range []string{"a", "b"} {
+				_, err := store.Allocate(request(containerID), podCIDR, subnet)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := store.Release(test.release)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := store.Addresses[test.release.key()]; ok {
+				t.Errorf("%s is still allocated", test.release.key())
+			}
+
+			ip, err := store.Allocate(request("c"), podCIDR, subnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != test.want {
+				t.Errorf("allocated %s, want %s", ip, test.want)
+			}
+		})
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	podCIDR := mustParseCIDR(t, "10.0.1.0/30")
+	subnet := mustParseCIDR(t, "10.0.0.0/16")
+
+	tests := []struct {
+		name    string
+		release bool
+		want    map[string]string
+	}{
+		{
+			name: "allocation is saved",
+			want: map[string]string{"pn/a/eth1": "10.0.1.0"},
+		},
+		{
+			name:    "release is saved",
+			release: true,
+			want:    map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newStore(t)
+			_, err := store.Allocate(request("a"), podCIDR, subnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.release {
+				err = store.Release(request("a"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the temporary file is renamed over the store
+			_, err = os.Stat(store.path + ".tmp")
+			if !os.IsNotExist(err) {
+				t.Errorf("temporary file left behind: %v", err)
+			}
+
+			content, err := ioutil.ReadFile(store.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := &Store{}
+			err = json.Unmarshal(content, saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(saved.Addresses) != len(test.want) {
+				t.Fatalf("saved %v, want %v", saved.Addresses, test.want)
+			}
+			for key, ip := range test.want {
+				if saved.Addresses[key] != ip {
+					t.Errorf("saved %s for %s, want %s", saved.Addresses[key], key, ip)
+				}
+			}
+		})
+	}
+}
+
+func TestRestart(t *testing.T) {
+	podCIDR := mustParseCIDR(t, "10.0.1.0/30")
+	subnet := mustParseCIDR(t, "10.0.0.0/16")
+
+	tests := []struct {
+		name    string
+		content string
+		// want are the recovered addresses, by container ID
+		want      map[string]string
+		wantErr   bool
+		nextAlloc string
+	}{
+		{
+			name:      "missing file",
+			want:      map[string]string{},
+			nextAlloc: "10.0.1.0",
+		},
+		{
+			name:      "allocations are recovered",
+			content:   `{"addresses":{"pn/a/eth1":"10.0.1.0","pn/b/eth1":"10.0.1.1"}}`,
+			want:      map[string]string{"a": "10.0.1.0", "b": "10.0.1.1"},
+			nextAlloc: "10.0.1.2",
+		},
+		{
+			name:      "empty store",
+			content:   `{}`,
+			want:      map[string]string{},
+			nextAlloc: "10.0.1.0",
+		},
+		{
+			name:    "corrupted file",
+			content: `{"addresses":`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pods.json")
+			if test.content != "" {
+				err := ioutil.WriteFile(path, []byte(test.content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store, err := LoadStore(path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(store.Addresses) != len(test.want) {
+				t.Fatalf("loaded %v, want %v", store.Addresses, test.want)
+			}
+			for containerID, ip := range test.want {
+				if store.Addresses[request(containerID).key()] != ip {
+					t.Errorf("loaded %s for %s, want %s", store.Addresses[request(containerID).key()], containerID, ip)
+				}
+			}
+
+			// the recovered allocations are kept and not given to another container
+			for containerID, ip := range test.want {
+				got, err := store.Allocate(request(containerID), podCIDR, subnet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.String() != ip {
+					t.Errorf("allocated %s to %s, want %s", got, containerID, ip)
+				}
+			}
+			got, err := store.Allocate(request("new"), podCIDR, subnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.nextAlloc {
+				t.Errorf("allocated %s, want %s", got, test.nextAlloc)
+			}
+		})
+	}
+}
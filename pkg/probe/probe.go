@@ -0,0 +1,108 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// protocolICMP is the IANA protocol number of ICMP
+	protocolICMP = 1
+)
+
+var (
+	echoPayload = []byte("scaleway-k8s-vpc")
+	echoSeq     uint32
+)
+
+// ICMP sends an echo request from src to dst and returns the round trip time
+func ICMP(src net.IP, dst net.IP, timeout time.Duration) (time.Duration, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", src.String())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&echoSeq, 1) & 0xffff)
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   id,
+			Seq:  seq,
+			Data: echoPayload,
+		},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	err = conn.SetDeadline(start.Add(timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = conn.WriteTo(b, &net.IPAddr{IP: dst})
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if addr, ok := peer.(*net.IPAddr); !ok || !addr.IP.Equal(dst) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.ID != id || echo.Seq != seq || !bytes.Equal(echo.Data, echoPayload) {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
+
+// TCP opens a TCP connection from src to dst on the given port and returns the time it took.
+// A refused connection still proves the target is reachable.
+func TCP(src net.IP, dst net.IP, port int32, timeout time.Duration) (time.Duration, error) {
+	if port == 0 {
+		return 0, fmt.Errorf("no port given for tcp probe")
+	}
+
+	dialer := net.Dialer{
+		Timeout:   timeout,
+		LocalAddr: &net.TCPAddr{IP: src},
+	}
+
+	start := time.Now()
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(dst.String(), strconv.Itoa(int(port))))
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return time.Since(start), nil
+		}
+		return 0, err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}